@@ -0,0 +1,184 @@
+package csv
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/type/date"
+	"google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"google.golang.org/genproto/googleapis/type/money"
+	"google.golang.org/genproto/googleapis/type/timeofday"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// builtin returns the built-in formatter of well-known types or nil.
+func (m *Marshaler) builtin(t reflect.Type) Formatter {
+	if followPtrType(t) == timeType {
+		return func(v interface{}, printf func(format string, a ...any) string) string {
+			switch v := v.(type) {
+			case time.Time:
+				return m.Time.format(printf, v)
+			case *time.Time:
+				return m.Time.format(printf, *v)
+			}
+			return ""
+		}
+	}
+	if fm := m.protoBuiltin(protoFullName(t)); fm != nil {
+		return func(v interface{}, printf func(format string, a ...any) string) string {
+			return fm(pointerTo(v), printf)
+		}
+	}
+	return nil
+}
+
+// pointerTo returns a pointer to a copy of v if v is a struct (e.g. a message
+// value field) so formatters of messages handle values and pointers alike.
+func pointerTo(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Struct {
+		return v
+	}
+	p := reflect.New(rv.Type())
+	p.Elem().Set(rv)
+	return p.Interface()
+}
+
+// protoBuiltin returns the built-in formatter of the well-known message n or
+// nil. The formatters expect pointers to the message.
+func (m *Marshaler) protoBuiltin(n protoreflect.FullName) Formatter {
+	switch n {
+	case "google.protobuf.Timestamp":
+		return func(v interface{}, printf func(format string, a ...any) string) string {
+			ts, _ := v.(*timestamppb.Timestamp)
+			return m.Time.format(printf, ts.AsTime())
+		}
+	case "google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue", "google.protobuf.Any":
+		return jsonFormatter
+	case "google.type.Money":
+		return func(v interface{}, printf func(format string, a ...any) string) string {
+			mo, _ := v.(*money.Money)
+			return localiseDecimal(printf, amount(mo)) + " " + mo.GetCurrencyCode()
+		}
+	case "google.type.Decimal":
+		return func(v interface{}, printf func(format string, a ...any) string) string {
+			d, _ := v.(*decimal.Decimal)
+			return localiseDecimal(printf, d.GetValue())
+		}
+	case "google.type.Date":
+		return func(v interface{}, printf func(format string, a ...any) string) string {
+			d, _ := v.(*date.Date)
+			switch {
+			case d.GetYear() == 0:
+				// anniversary
+				return fmt.Sprintf("--%02d-%02d", d.GetMonth(), d.GetDay())
+			case d.GetMonth() == 0:
+				return fmt.Sprintf("%04d", d.GetYear())
+			case d.GetDay() == 0:
+				return fmt.Sprintf("%04d-%02d", d.GetYear(), d.GetMonth())
+			}
+			return fmt.Sprintf("%04d-%02d-%02d", d.GetYear(), d.GetMonth(), d.GetDay())
+		}
+	case "google.type.TimeOfDay":
+		return func(v interface{}, printf func(format string, a ...any) string) string {
+			t, _ := v.(*timeofday.TimeOfDay)
+			s := fmt.Sprintf("%02d:%02d:%02d", t.GetHours(), t.GetMinutes(), t.GetSeconds())
+			if t.GetNanos() != 0 {
+				s += strings.TrimRight(fmt.Sprintf(".%09d", t.GetNanos()), "0")
+			}
+			return s
+		}
+	case "google.type.LatLng":
+		return func(v interface{}, printf func(format string, a ...any) string) string {
+			l, _ := v.(*latlng.LatLng)
+			sep := ","
+			if m.FieldDelim == sep {
+				sep = m.InnerDelim
+			}
+			return strconv.FormatFloat(l.GetLatitude(), 'f', -1, 64) + sep + strconv.FormatFloat(l.GetLongitude(), 'f', -1, 64)
+		}
+	}
+	return nil
+}
+
+// amount returns the exact decimal amount of mo with at least two decimals.
+func amount(mo *money.Money) string {
+	units, nanos := mo.GetUnits(), int64(mo.GetNanos())
+	sign := ""
+	if units < 0 || nanos < 0 {
+		sign = "-"
+	}
+	if units < 0 {
+		units = -units
+	}
+	if nanos < 0 {
+		nanos = -nanos
+	}
+	frac := strings.TrimRight(fmt.Sprintf("%09d", nanos), "0")
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	return sign + strconv.FormatInt(units, 10) + "." + frac
+}
+
+// marshalMoney renders the google.type.Money value v of field f with path p
+// to the two cells amount and currency (see SplitMoney option). Like
+// numbers the amount is not sanitized.
+func (m *Marshaler) marshalMoney(p string, f reflect.StructField, v reflect.Value) []string {
+	if v.Kind() == reflect.Ptr && v.IsNil() || !v.CanInterface() {
+		return []string{m.Null, m.Null}
+	}
+	mo, _ := pointerTo(v.Interface()).(*money.Money)
+	return []string{localiseDecimal(m.Printf, amount(mo)), m.sanitize(p, f, mo.GetCurrencyCode())}
+}
+
+// localiseDecimal replaces the decimal point of the number s by the
+// decimal separator of printf. Grouping is not applied as it is not
+// understood by spreadsheets.
+func localiseDecimal(printf func(format string, a ...any) string, s string) string {
+	if strings.Contains(printf("%v", 0.5), ",") {
+		return strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+// TimeFormat specifies the formatting of timestamps.
+type TimeFormat struct {
+	// Layout is the layout passed to time.Time.Format. Defaults to time.RFC3339Nano.
+	Layout string
+	// Location is the time zone timestamps are rendered in. Defaults to UTC.
+	Location *time.Location
+	// Excel renders timestamps as Excel serial date (fractional days since
+	// 1899-12-30 in Location), Layout is ignored. Only the decimal separator
+	// is localised.
+	Excel bool
+}
+
+// excelUnixEpoch is the Excel serial date of 1970-01-01 (1900 date system).
+const excelUnixEpoch = 25569
+
+func (tf TimeFormat) format(printf func(format string, a ...any) string, t time.Time) string {
+	loc := tf.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	if tf.Excel {
+		// wall clock of t in Location
+		_, offset := t.Zone()
+		secs := float64(t.Unix()+int64(offset)) + float64(t.Nanosecond())/1e9
+		return localiseDecimal(printf, strconv.FormatFloat(secs/(24*60*60)+excelUnixEpoch, 'f', -1, 64))
+	}
+	layout := tf.Layout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	return t.Format(layout)
+}
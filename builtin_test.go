@@ -0,0 +1,272 @@
+package csv
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/kylelemons/godebug/pretty"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"google.golang.org/genproto/googleapis/type/date"
+	"google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"google.golang.org/genproto/googleapis/type/money"
+	"google.golang.org/genproto/googleapis/type/timeofday"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type event struct {
+	Name    string
+	Created *timestamppb.Timestamp
+	Updated time.Time
+	History []*timestamppb.Timestamp
+}
+
+func TestMarshaler_MarshalTime(t *testing.T) {
+
+	ts := time.Date(2026, 10, 17, 12, 30, 0, 0, time.UTC)
+	v := []event{
+		{
+			Name:    "a",
+			Created: timestamppb.New(ts),
+			Updated: ts.Add(time.Second / 2),
+			History: []*timestamppb.Timestamp{timestamppb.New(ts)},
+		},
+		{
+			Name: "b",
+		},
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		want string
+	}{
+		{
+			name: "rfc 3339",
+			m:    &Marshaler{Null: "NULL"},
+			want: "Name;Created;Updated;History\n" +
+				"a;2026-10-17T12:30:00Z;2026-10-17T12:30:00.5Z;2026-10-17T12:30:00Z\n" +
+				"b;NULL;0001-01-01T00:00:00Z;\n",
+		},
+		{
+			name: "layout and location",
+			m:    &Marshaler{Time: TimeFormat{Layout: "02.01.2006 15:04", Location: time.FixedZone("CEST", 2*60*60)}},
+			want: "Name;Created;Updated;History\n" +
+				"a;17.10.2026 14:30;17.10.2026 14:30;17.10.2026 14:30\n" +
+				"b;;01.01.0001 02:00;\n",
+		},
+		{
+			name: "excel",
+			m:    &Marshaler{Time: TimeFormat{Excel: true}},
+			want: "Name;Created;Updated;History\n" +
+				"a;46312.52083333333;46312.52083912037;46312.52083333333\n" +
+				"b;;-693593;\n",
+		},
+		{
+			name: "excel sanitized",
+			m:    &Marshaler{Time: TimeFormat{Excel: true}, Sanitize: SanitizeQuote},
+			want: "Name;Created;Updated;History\n" +
+				"a;46312.52083333333;46312.52083912037;46312.52083333333\n" +
+				"b;;-693593;\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshaler_MarshalTimezone(t *testing.T) {
+
+	v := []struct{ Created *timestamppb.Timestamp }{{timestamppb.New(time.Date(2026, 1, 17, 12, 30, 0, 0, time.UTC))}}
+	m := &Marshaler{NoHeader: true, TimezoneHeader: "X-Timezone", TimezoneParam: "tz"}
+
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		want   string
+	}{
+		{
+			name: "default",
+			url:  "/v1/test",
+			want: "2026-01-17T12:30:00Z\n",
+		},
+		{
+			name:   "header",
+			url:    "/v1/test",
+			header: "America/New_York",
+			want:   "2026-01-17T07:30:00-05:00\n",
+		},
+		{
+			name:   "query parameter",
+			url:    "/v1/test?tz=Europe/Berlin",
+			header: "America/New_York",
+			want:   "2026-01-17T13:30:00+01:00\n",
+		},
+		{
+			name: "unknown zone",
+			url:  "/v1/test?tz=Mars/Olympus",
+			want: "2026-01-17T12:30:00Z\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			req.Header.Set("Accept", "text/csv")
+			if tt.header != "" {
+				req.Header.Set("X-Timezone", tt.header)
+			}
+			g, err := m.MarshalContext(context.WithValue(context.Background(), requestKey{}, req), v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.MarshalContext() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+type order struct {
+	Price    *money.Money
+	Amount   *decimal.Decimal
+	Day      *date.Date
+	At       *timeofday.TimeOfDay
+	Location *latlng.LatLng
+}
+
+func TestMarshaler_MarshalCommonTypes(t *testing.T) {
+
+	v := []order{
+		{
+			Price:    &money.Money{CurrencyCode: "EUR", Units: 12, Nanos: 340000000},
+			Amount:   &decimal.Decimal{Value: "1.25"},
+			Day:      &date.Date{Year: 2026, Month: 10, Day: 17},
+			At:       &timeofday.TimeOfDay{Hours: 9, Minutes: 5, Nanos: 500000000},
+			Location: &latlng.LatLng{Latitude: 52.5, Longitude: 13.4},
+		},
+		{
+			Price:  &money.Money{CurrencyCode: "USD", Units: -1, Nanos: -5},
+			Amount: &decimal.Decimal{Value: "-1.5"},
+			Day:    &date.Date{Year: 2026, Month: 10},
+		},
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		want string
+	}{
+		{
+			name: "one cell",
+			m:    &Marshaler{},
+			want: "Price;Amount;Day;At;Location\n" +
+				"12.34 EUR;1.25;2026-10-17;09:05:00.5;52.5,13.4\n" +
+				"-1.000000005 USD;-1.5;2026-10;;\n",
+		},
+		{
+			name: "split money",
+			m:    &Marshaler{SplitMoney: true, FieldDelim: ","},
+			want: "Price,PriceCurrency,Amount,Day,At,Location\n" +
+				"12.34,EUR,1.25,2026-10-17,09:05:00.5,52.5|13.4\n" +
+				"-1.000000005,USD,-1.5,2026-10,,\n",
+		},
+		{
+			name: "sanitized",
+			m:    &Marshaler{Sanitize: SanitizeQuote},
+			want: "Price;Amount;Day;At;Location\n" +
+				"12.34 EUR;1.25;2026-10-17;09:05:00.5;52.5,13.4\n" +
+				"-1.000000005 USD;-1.5;2026-10;;\n",
+		},
+		{
+			name: "split money sanitized",
+			m:    &Marshaler{SplitMoney: true, Sanitize: SanitizeQuote},
+			want: "Price;PriceCurrency;Amount;Day;At;Location\n" +
+				"12.34;EUR;1.25;2026-10-17;09:05:00.5;52.5,13.4\n" +
+				"-1.000000005;USD;-1.5;2026-10;;\n",
+		},
+		{
+			name: "localised",
+			m: &Marshaler{
+				Printf: func(format string, a ...any) string { return message.NewPrinter(language.German).Sprintf(format, a...) },
+			},
+			want: "Price;Amount;Day;At;Location\n" +
+				"12,34 EUR;1,25;2026-10-17;09:05:00.5;52.5,13.4\n" +
+				"-1,000000005 USD;-1,5;2026-10;;\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+type orderValue struct {
+	Price money.Money
+	Day   date.Date
+	At    timestamppb.Timestamp
+}
+
+func TestMarshaler_MarshalCommonTypeValues(t *testing.T) {
+
+	v := []orderValue{{
+		Price: money.Money{CurrencyCode: "EUR", Units: 12, Nanos: 340000000},
+		Day:   date.Date{Year: 2026, Month: 1, Day: 2},
+		At:    timestamppb.Timestamp{Seconds: 1},
+	}}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		want string
+	}{
+		{
+			name: "one cell",
+			m:    &Marshaler{},
+			want: "Price;Day;At\n12.34 EUR;2026-01-02;1970-01-01T00:00:01Z\n",
+		},
+		{
+			name: "split money",
+			m:    &Marshaler{SplitMoney: true},
+			want: "Price;PriceCurrency;Day;At\n12.34;EUR;2026-01-02;1970-01-01T00:00:01Z\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
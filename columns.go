@@ -0,0 +1,121 @@
+package csv
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ColumnOptions overrides marshaler options for a single column. Zero
+// values inherit the option of the Marshaler.
+type ColumnOptions struct {
+	// Sanitize overrides Marshaler.Sanitize
+	Sanitize Sanitize
+	// Bool overrides Marshaler.Bool
+	Bool BoolFormat
+	// Float overrides Marshaler.Float
+	Float FloatFormat
+	// Int64 overrides Marshaler.Int64
+	Int64 Int64
+	// Formatter renders the values of the column. Takes precedence over
+	// Marshaler.ProtoFormatters and Marshaler.TypeFormatters.
+	Formatter Formatter
+	// JSON renders nested messages, slices and maps of the column as compact
+	// JSON to one cell instead of flattening them.
+	JSON bool
+}
+
+// column returns the options of the column identified by the field path p
+// or the header name n.
+func (m *Marshaler) column(p, n string) ColumnOptions {
+	if o, ok := m.Columns[p]; ok {
+		return o
+	}
+	return m.Columns[n]
+}
+
+// fieldPath appends the name of field f to the field path p.
+func fieldPath(p string, f reflect.StructField) string {
+	if p == "" {
+		return name(f)
+	}
+	return p + "." + name(f)
+}
+
+// cell post-processes the rendered value s of field f with path p
+// according to the column options.
+func (m *Marshaler) cell(p string, f reflect.StructField, s string) string {
+	if m.numeric(f.Type) {
+		return s
+	}
+	return m.sanitize(p, f, s)
+}
+
+// sanitize applies the Sanitize option of field f with path p to s.
+func (m *Marshaler) sanitize(p string, f reflect.StructField, s string) string {
+	o := m.column(p, name(f))
+
+	sanitize := m.Sanitize
+	if o.Sanitize != SanitizeDefault {
+		sanitize = o.Sanitize
+	}
+	return sanitize.apply(s)
+}
+
+// numeric reports whether t is a number or a slice / pointer of numbers or a
+// map with numeric keys and values. Bytes are no numbers. google.type.Money
+// and Decimal are numbers as are timestamps rendered as Excel serial dates.
+func (m *Marshaler) numeric(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return false
+	}
+	if followPtrType(t) == timeType {
+		return m.Time.Excel
+	}
+	switch protoFullName(t) {
+	case "google.type.Money", "google.type.Decimal":
+		return true
+	case "google.protobuf.Timestamp":
+		return m.Time.Excel
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return m.numeric(t.Key()) && m.numeric(t.Elem())
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return m.numeric(t.Elem())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// Sanitize specifies how cells are neutralised which would be interpreted as
+// formula by spreadsheet applications (starting with '=', '+', '-', '@', tab or CR).
+type Sanitize int
+
+const (
+	// SanitizeDefault does not sanitise cells. Used in ColumnOptions it inherits Marshaler.Sanitize.
+	SanitizeDefault Sanitize = iota
+	// SanitizeNone does not sanitise cells.
+	SanitizeNone
+	// SanitizeQuote prefixes formula cells with a single quote.
+	SanitizeQuote
+	// SanitizeStrip removes the leading formula characters from cells.
+	SanitizeStrip
+)
+
+const formulaChars = "=+-@\t\r"
+
+func (s Sanitize) apply(c string) string {
+	if c == "" || !strings.ContainsRune(formulaChars, rune(c[0])) {
+		return c
+	}
+	switch s {
+	case SanitizeQuote:
+		return "'" + c
+	case SanitizeStrip:
+		return strings.TrimLeft(c, formulaChars)
+	}
+	return c
+}
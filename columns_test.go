@@ -0,0 +1,80 @@
+package csv
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+type formula struct {
+	A     string
+	B     string
+	N     int
+	F     []float64
+	S     []string
+	Inner inner
+	M     map[string]int64
+}
+
+func TestMarshaler_MarshalSanitize(t *testing.T) {
+
+	v := []formula{
+		{
+			A:     "=1+2",
+			B:     "@SUM(A1)",
+			N:     -1,
+			F:     []float64{-1.5, 2},
+			S:     []string{"-x", "y"},
+			Inner: inner{Col3: "+cmd"},
+			M:     map[string]int64{"=HYPERLINK(1)": 1},
+		},
+		{
+			A: "\tx",
+			B: "ok",
+		},
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		want string
+	}{
+		{
+			name: "no sanitisation",
+			m:    &Marshaler{NoHeader: true},
+			want: "=1+2;@SUM(A1);-1;-1.5|2;-x|y;+cmd;0;0;=HYPERLINK(1):1\n\tx;ok;0;;;;0;0;\n",
+		},
+		{
+			name: "quote",
+			m:    &Marshaler{NoHeader: true, Sanitize: SanitizeQuote},
+			want: "'=1+2;'@SUM(A1);-1;-1.5|2;'-x|y;'+cmd;0;0;'=HYPERLINK(1):1\n'\tx;ok;0;;;;0;0;\n",
+		},
+		{
+			name: "column overrides",
+			m: &Marshaler{
+				NoHeader: true,
+				Sanitize: SanitizeQuote,
+				Columns: map[string]ColumnOptions{
+					"A":          {Sanitize: SanitizeStrip},
+					"B":          {Sanitize: SanitizeNone},
+					"Inner.Col3": {Sanitize: SanitizeStrip},
+					"Col3":       {Sanitize: SanitizeNone},
+				},
+			},
+			want: "1+2;@SUM(A1);-1;-1.5|2;'-x|y;cmd;0;0;'=HYPERLINK(1):1\nx;ok;0;;;;0;0;\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package csv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Compress wraps the gateway mux h to gzip compress responses of the
+// marshalers (text/csv, text/plain and multipart/mixed) if the request
+// accepts the gzip content coding. Other responses (e.g. JSON) and
+// compressed bodies (application/zip, application/gzip) are passed through.
+// Bodies are compressed while written, flushing the response flushes the
+// compressor.
+func Compress(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &compressWriter{ResponseWriter: w, accept: acceptsGzip(r.Header.Get("Accept-Encoding"))}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// compressible reports whether the content type ct is rendered by the
+// marshalers and not compressed already.
+func compressible(ct string) bool {
+	t, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	switch t {
+	case "text/csv", "text/plain", "multipart/mixed":
+		return true
+	}
+	return false
+}
+
+// acceptsGzip reports whether the Accept-Encoding header h accepts gzip
+// (or any coding) with a non-zero quality.
+func acceptsGzip(h string) bool {
+	for _, e := range strings.Split(h, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(e), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "x-gzip" && coding != "*" {
+			continue
+		}
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		return q > 0
+	}
+	return false
+}
+
+// compressWriter decides on compression when the header is written.
+type compressWriter struct {
+	http.ResponseWriter
+	// accept is set if the request accepts gzip
+	accept      bool
+	wroteHeader bool
+	gz          *gzip.Writer
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if compressible(h.Get("Content-Type")) && h.Get("Content-Encoding") == "" &&
+		code != http.StatusNoContent && code != http.StatusNotModified {
+		h.Add("Vary", "Accept-Encoding")
+		if w.accept {
+			h.Set("Content-Encoding", "gzip")
+			h.Del("Content-Length")
+			w.gz = gzip.NewWriter(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the compressor and the underlying response. The header is
+// written first if it was not yet.
+func (w *compressWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) close() error {
+	if w.gz == nil {
+		return nil
+	}
+	return w.gz.Close()
+}
+
+// compress gzip compresses the body b if the Gzip option is set.
+func (m *Marshaler) compress(b []byte, err error) ([]byte, error) {
+	if err != nil || !m.Gzip {
+		return b, err
+	}
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
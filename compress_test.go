@@ -0,0 +1,128 @@
+package csv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func gunzip(t *testing.T, b []byte) string {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	s, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("gzip.Reader.Read() error = %v", err)
+	}
+	return string(s)
+}
+
+func TestCompress(t *testing.T) {
+	h := Compress(gateway(t, &Marshaler{Single: SingleKeyValue}, wrapperspb.String("a")))
+
+	tests := []struct {
+		name           string
+		accept         string
+		acceptEncoding string
+		wantEncoding   string
+		want           string
+	}{
+		{
+			name:   "no accept-encoding",
+			accept: "text/csv",
+			want:   "field;value\nValue;a\n",
+		},
+		{
+			name:           "gzip",
+			accept:         "text/csv",
+			acceptEncoding: "br, gzip",
+			wantEncoding:   "gzip",
+			want:           "field;value\nValue;a\n",
+		},
+		{
+			name:           "gzip refused",
+			accept:         "text/csv",
+			acceptEncoding: "gzip;q=0, br",
+			want:           "field;value\nValue;a\n",
+		},
+		{
+			name:           "other marshaler",
+			accept:         "application/json",
+			acceptEncoding: "gzip",
+			want:           "\"a\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/test", nil)
+			req.Header.Set("Accept", tt.accept)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %v, want %v", got, tt.wantEncoding)
+			}
+			got := w.Body.String()
+			if tt.wantEncoding == "gzip" {
+				got = gunzip(t, w.Body.Bytes())
+			}
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("Compress() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshaler_MarshalGzip(t *testing.T) {
+	m := &Marshaler{Single: SingleRow, Gzip: true, Attachment: true}
+	resp := wrapperspb.String("a")
+
+	if got := m.ContentType(resp); got != "application/gzip" {
+		t.Errorf("Marshaler.ContentType() = %v, want application/gzip", got)
+	}
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", m.ContentType(resp))
+	if err := m.ForwardResponseOption(httptest.NewRequest("GET", "/", nil).Context(), w, resp); err != nil {
+		t.Fatalf("Marshaler.ForwardResponseOption() error = %v", err)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != "attachment; filename=StringValue.csv.gz" {
+		t.Errorf("Content-Disposition = %v", got)
+	}
+
+	b, err := m.Marshal(resp)
+	if err != nil {
+		t.Fatalf("Marshaler.Marshal() error = %v", err)
+	}
+	if diff := pretty.Compare(gunzip(t, b), "Value\na\n"); diff != "" {
+		t.Errorf("Marshaler.Marshal() generate unexpected results:\n%s", diff)
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	h := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.(http.Flusher).Flush()
+		w.Write([]byte("a;b\n"))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	// headers as sent with the first flush
+	if got := w.Result().Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %v, want gzip", got)
+	}
+	if diff := pretty.Compare(gunzip(t, w.Body.Bytes()), "a;b\n"); diff != "" {
+		t.Errorf("Compress() generate unexpected results:\n%s", diff)
+	}
+}
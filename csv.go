@@ -1,13 +1,23 @@
 package csv
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
+	"sync/atomic"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
+// Marshaler renders responses as CSV (see Marshal). The package provides no
+// Unmarshal, cells are parsed back using Bytes.Decode, BoolFormat.Parse and
+// ParseJSON.
 type Marshaler struct {
 	runtime.Marshaler
 
@@ -17,12 +27,153 @@ type Marshaler struct {
 	FieldDelim string
 	// InnerDelim specifies the delimiter of merged values (slices / maps) within one field.
 	InnerDelim string
+	// BlockDelim specifies the delimiter between blocks of multiple top-level slices.
+	BlockDelim string
 
 	// used to print types (e.g. int, float, ...)
 	Printf func(format string, a ...any) string
+	// Languages enables locale aware formatting per request. The Accept-Language
+	// header is matched against the supported languages, the printer of the
+	// matching language replaces Printf. Requires Handler and ForwardResponseOption.
+	Languages []language.Tag
+	// AutoDelim selects the FieldDelim by locale: ',' if the matched language uses a
+	// decimal point, ';' if it uses a decimal comma.
+	AutoDelim bool
+	// Catalog translates headers to the language of the request (or the first of
+	// Languages). Keys are field paths (e.g. 'Inner.Col3') or proto full names of
+	// fields (e.g. 'example.Outer.col1'). Untranslated headers keep their name.
+	Catalog catalog.Catalog
+
+	// headers translates headers using Catalog, set per request
+	headers *message.Printer
+	// err is the first error of the current marshaling, e.g. ErrMaxDepth
+	err error
+	// used accounts the current marshaling against MaxRows and MaxBytes
+	used usage
+
+	// Encoding specifies the character encoding of the output, e.g. unicode.UTF8BOM,
+	// unicode.UTF16(unicode.LittleEndian, unicode.UseBOM) or charmap.Windows1252.
+	// Defaults to UTF-8 without BOM.
+	Encoding encoding.Encoding
+	// StrictEncoding returns an error for characters not representable in Encoding.
+	// By default those are replaced by '?'.
+	StrictEncoding bool
+
+	// Sanitize neutralises cells which would be interpreted as formula by spreadsheet
+	// applications. Numeric fields are never sanitised. Defaults to no sanitisation.
+	Sanitize Sanitize
+
+	// TypeFormatters renders values by Go type, falling back to Printf.
+	TypeFormatters map[reflect.Type]Formatter
+	// ProtoFormatters renders messages by proto full name (e.g. 'google.type.Money') into one cell.
+	// Takes precedence over TypeFormatters.
+	ProtoFormatters map[protoreflect.FullName]Formatter
+
+	// Bytes specifies the encoding of bytes fields. Defaults to BytesBase64.
+	Bytes Bytes
+	// Bool specifies the rendering of booleans. Defaults to Printf("%v").
+	Bool BoolFormat
+	// Float specifies the formatting of floating point values. Defaults to Printf("%v").
+	Float FloatFormat
+	// Time specifies the formatting of google.protobuf.Timestamp and time.Time values.
+	// Defaults to RFC 3339 in UTC.
+	Time TimeFormat
+	// TimezoneHeader names a request header (e.g. 'X-Timezone') selecting the IANA time
+	// zone of Time per request. Requires Handler and ForwardResponseOption.
+	TimezoneHeader string
+	// TimezoneParam names a query parameter (e.g. 'tz') selecting the IANA time zone of
+	// Time per request. Takes precedence over TimezoneHeader.
+	TimezoneParam string
+	// SplitMoney renders google.type.Money fields to two columns: the amount and the currency
+	// ('<name>Currency'). By default both are rendered to one cell (e.g. '12.34 EUR').
+	SplitMoney bool
+	// Int64 forces 64-bit integer fields to be treated as text by spreadsheet applications,
+	// which lose precision above 2^53. Defaults to plain numbers.
+	Int64 Int64
+
+	// MaxDepth limits the nesting level of flattened columns (e.g. 2 flattens
+	// 'Inner.Col3' but not 'Inner.Deep.Col3'), which bounds the columns of recursive
+	// messages (e.g. trees of nodes). Messages with deeper columns are rendered
+	// according to the Recursion option, e.g. as compact JSON to one cell using
+	// RecursionJSON (see also ColumnOptions.JSON). Defaults to 0, which is unlimited.
+	MaxDepth int
+	// Recursion specifies how messages nested deeper than MaxDepth are rendered.
+	// Defaults to RecursionTruncate.
+	Recursion Recursion
+
+	// MaxRows limits the number of rows of all blocks. Defaults to 0, which is unlimited.
+	MaxRows int
+	// MaxBytes approximately limits the size of the body: it counts the header and row
+	// lines of all blocks before encoding. Block titles, section columns, block delimiters,
+	// scalar comments, packaging and table padding are not counted. A block whose header
+	// exceeds MaxBytes is rendered as TruncateMarker only. Defaults to 0, which is unlimited.
+	MaxBytes int
+	// Truncate renders the rows within MaxRows and MaxBytes followed by TruncateMarker
+	// instead of returning a LimitError.
+	Truncate bool
+	// TruncateMarker is the row appended to a truncated block. Defaults to DefaultTruncateMarker.
+	TruncateMarker string
+
+	// Sort sorts the rows of each block by a comma separated list of fields (field
+	// paths or header names), descending if prefixed by '-' (e.g. '-Col4,Inner.Col3').
+	// Rows are compared by the field values, not the rendered cells. Fields unknown
+	// to a block or of types without ordering (e.g. messages other than timestamps,
+	// wrappers, google.type.Money and Decimal) are ignored.
+	Sort string
+	// SortParam names a query parameter (e.g. 'csv.sort') overriding Sort per request.
+	// Requires Handler and ForwardResponseOption.
+	SortParam string
+
+	// Null renders nil messages (e.g. unset wrapper types), one cell per column of the message.
+	Null string
+	// Unset renders unset optional scalars (proto3 optional fields).
+	Unset string
+	// Empty renders empty repeated fields and maps.
+	Empty string
+
+	// Columns overrides options per column. Keys are field paths (e.g. 'Inner.Col3')
+	// or header names, field paths take precedence.
+	Columns map[string]ColumnOptions
 
 	// NoHeader suppresses to render the header
 	NoHeader bool
+	// BlockTitle renders a title line ('# <field name>') in front of each block
+	BlockTitle bool
+	// SectionColumn prepends a 'section' column containing the field name of the block
+	SectionColumn bool
+
+	// Packaging specifies how blocks of multiple top-level slices are packaged. Defaults to PackInline.
+	Packaging Packaging
+	// Boundary specifies the multipart boundary used with PackMultipart. Defaults to a
+	// random boundary generated once per Marshaler. Marshaling fails if a block
+	// contains the boundary.
+	Boundary string
+	// generatedBoundary is the default of Boundary
+	generatedBoundary atomic.Value
+
+	// Tables selects the slice field rendered for a response type, all other slices are ignored.
+	// Keys are proto full names (e.g. 'example.v1.ListFooResponse') or Go type names
+	// (e.g. 'examplepb.ListFooResponse'), values are field names (Go, csv tag or proto name).
+	Tables map[string]string
+	// ScalarComments appends non-zero scalar fields of the response as comment lines ('# <name>: <value>').
+	ScalarComments bool
+	// Single specifies how responses without slices of structs are rendered. Defaults to SingleNone.
+	Single Single
+	// ScalarHeaderPrefix exposes non-zero scalar fields of the response as HTTP headers
+	// named '<prefix><name>' (e.g. 'X-Csv-' results in 'X-Csv-Next-Page-Token').
+	// Requires registration of ForwardResponseOption.
+	ScalarHeaderPrefix string
+
+	// Gzip compresses the body and serves it as 'application/gzip', with Attachment
+	// as '.csv.gz' download. To compress depending on Accept-Encoding use Compress.
+	Gzip bool
+
+	// Attachment sets the Content-Disposition header to offer the response as download.
+	// Requires registration of ForwardResponseOption.
+	Attachment bool
+	// Filename is a text/template rendering the attachment filename. Available fields
+	// are .Service, .Method and .Ext. Defaults to DefaultFilename.
+	Filename string
 }
 
 func (m *Marshaler) initDefaults() {
@@ -35,63 +186,210 @@ func (m *Marshaler) initDefaults() {
 	if m.InnerDelim == "" {
 		m.InnerDelim = "|"
 	}
+	if m.BlockDelim == "" {
+		m.BlockDelim = "---\n"
+	}
 	if m.Printf == nil {
 		m.Printf = fmt.Sprintf
 	}
+	if m.Packaging == PackMultipart {
+		// before per request copies are made
+		m.boundary()
+	}
+	if m.Filename == "" {
+		m.Filename = DefaultFilename
+	}
+	if m.TruncateMarker == "" {
+		m.TruncateMarker = DefaultTruncateMarker
+	}
 }
 
 // Marshal renders the structure in i as CSV.
 //
-// If i is a slice or a struct that contains slices each slice
-// is rendered to a CSV block. These top-level slices need to
-// contain structs otherwise an error is returned.
+// If i is a slice or a struct that contains slices of structs each slice
+// is rendered to a CSV block. Top-level slices need to contain structs
+// otherwise an error is returned, repeated scalar fields of a struct are
+// rendered as cells.
 // Each top-level slices is rendered to one block. The blocks
-// are delimited by '---\n'. Empty slices or nil pointers are ignored.
+// are delimited by m.BlockDelim. Empty slices or nil pointers are ignored.
+// Blocks may be labeled with their field name using the BlockTitle
+// or SectionColumn option.
+// Using the Packaging option the blocks are packaged to a ZIP archive
+// or a multipart/mixed body instead, one CSV file named after the
+// slice field per block.
+// Using the Tables option only one slice field of a response type is
+// rendered, sibling scalar fields (e.g. next page tokens) may be added
+// as trailing comments using the ScalarComments option.
+// Structs without slices of structs are ignored unless the Single option
+// selects to render them as one row or as 'field;value' pairs.
+// The MaxRows and MaxBytes options limit the rendered rows, exceeding
+// them returns a LimitError or truncates the blocks (see Truncate).
+// Error responses (google.rpc.Status) are rendered as table with the
+// columns code, message and details. Messages of server streams are
+// rendered one by one, each as if it was a unary response.
 //
 // Each csv block consists of a header (if NoHeader option is false) and
 // multiple rows delimited by m.RowDelimi. Each row is a 'flat'
 // representation of the corresponding slice elements:
 //   - struct fields are visible on top-level with own header delimited by m.FieldDelim
 //   - nested slices / maps are flatened delimited by m.InnerDelim
+//   - bytes are encoded as base64 (see Bytes option)
+//   - timestamps are rendered as RFC 3339 (see Time option)
+//   - google.type.Money, Date, TimeOfDay, LatLng and Decimal are rendered
+//     to one cell (e.g. '12.34 EUR', '2026-10-17', '52.5,13.4')
+//   - google.protobuf.Struct, Value, ListValue and Any are rendered as
+//     compact JSON, as are fields selected by ColumnOptions.JSON
+//   - messages nested deeper than MaxDepth are truncated, rendered as
+//     JSON or rejected (see Recursion option)
+//   - nil messages, unset optional scalars and empty slices / maps are
+//     rendered as m.Null, m.Unset and m.Empty
+//   - values are rendered by registered formatters (per column, proto
+//     message or Go type) falling back to m.Printf
+//
+// If i is a response bound to its request by ForwardResponseOption, request
+// specific options are applied, see MarshalContext.
 func (m *Marshaler) Marshal(i interface{}) ([]byte, error) {
+	return m.MarshalContext(boundContext(i), i)
+}
+
+// MarshalContext renders the structure in i as CSV like Marshal applying
+// request specific options. The request is taken from ctx if the gateway
+// is wrapped by Handler:
+//   - the locale used for formatting and header translation is selected by
+//     the Accept-Language header (see Languages and Catalog)
+//   - the time zone of timestamps is selected by a header or query parameter
+//     (see TimezoneHeader and TimezoneParam)
+//   - the sort order of rows is selected by a query parameter (see SortParam)
+//
+// Marshaling is aborted with the error of ctx once ctx is done (e.g. the
+// client disconnected). Marshal uses the request context for responses
+// bound by ForwardResponseOption.
+func (m *Marshaler) MarshalContext(ctx context.Context, i interface{}) ([]byte, error) {
 	m.initDefaults()
+	m = m.forRequest(ctx)
+	i = streamed(i)
+
+	blocks, err := m.blocks(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+	switch m.Packaging {
+	case PackZip:
+		return m.compress(m.zip(blocks))
+	case PackMultipart:
+		return m.compress(m.multipart(blocks))
+	}
+	slices := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		slices = append(slices, m.render(b))
+	}
+	res := strings.Join(slices, m.BlockDelim)
+	if m.ScalarComments {
+		for _, s := range m.scalars(i) {
+			res = res + fmt.Sprintf("# %s: %s%s", s.name, s.value, m.RowDelim)
+		}
+	}
+	return m.compress(m.encode(res))
+
+}
+
+// block is the flattened representation of one top-level slice.
+type block struct {
+	// name of the slice field the block was generated from
+	name string
+	// header is nil if it exceeded MaxBytes, only the truncate marker is rendered
+	header []string
+	rows   [][]string
+	// truncated is set if rows were dropped by MaxRows or MaxBytes
+	truncated bool
+}
+
+// title returns the name of the block. Blocks without field name
+// (top-level slice responses) are named 'data'.
+func (b block) title() string {
+	if b.name == "" {
+		return "data"
+	}
+	return b.name
+}
+
+// blocks flattens all top-level slices in i. Empty slices or nil pointers
+// do not generate a block. Flattening is aborted if ctx is done.
+func (m *Marshaler) blocks(ctx context.Context, i interface{}) ([]block, error) {
+	if s, ok := errorStatus(i); ok {
+		return []block{m.marshalStatus(s)}, nil
+	}
 
 	v := reflect.ValueOf(i)
 	v = followPtr(v)
 
-	slices := []string{}
+	blocks := []block{}
 	var err error
 	switch v.Kind() {
 	case reflect.Struct:
 		t := v.Type()
+		table, selected := m.table(i)
+		hasSlice := false
 		for i := 0; i < t.NumField(); i++ {
 			v := v.Field(i)
-			if v.Kind() == reflect.Slice {
-				slices, err = m.marshalSliceAndAppend(slices, v)
+			if selected && !matches(t.Field(i), table) {
+				continue
+			}
+			// proto internal fields
+			if t.Field(i).Name == "unknownFields" || strings.HasPrefix(t.Field(i).Name, "XXX_") {
+				continue
+			}
+			// repeated scalars (and bytes) are rendered as cells unless selected
+			if v.Kind() == reflect.Slice && (selected || followPtrType(v.Type().Elem()).Kind() == reflect.Struct) {
+				hasSlice = true
+				blocks, err = m.marshalSliceAndAppend(ctx, blocks, name(t.Field(i)), v)
 				if err != nil {
 					return nil, err
 				}
 			}
 		}
+		if !hasSlice {
+			if b := m.marshalSingle(v); b != nil {
+				blocks = append(blocks, *b)
+			}
+		}
 	case reflect.Slice:
-		slices, err = m.marshalSliceAndAppend(slices, v)
+		blocks, err = m.marshalSliceAndAppend(ctx, blocks, "", v)
 		if err != nil {
 			return nil, err
 		}
 	}
-	return []byte(strings.Join(slices, "---\n")), nil
-
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.SectionColumn {
+		for j := range blocks {
+			b := &blocks[j]
+			if b.header == nil {
+				continue
+			}
+			b.header = append([]string{"section"}, b.header...)
+			for i, row := range b.rows {
+				b.rows[i] = append([]string{b.title()}, row...)
+			}
+		}
+	}
+	return blocks, nil
 }
 
-func (m *Marshaler) marshalSliceAndAppend(slices []string, v reflect.Value) ([]string, error) {
-	s, err := m.marshalSlice(v)
+func (m *Marshaler) marshalSliceAndAppend(ctx context.Context, blocks []block, name string, v reflect.Value) ([]block, error) {
+	b, err := m.marshalSlice(ctx, v)
 	if err != nil {
 		return nil, err
 	}
-	if s != "" {
-		return append(slices, s), nil
+	if b != nil {
+		b.name = name
+		return append(blocks, *b), nil
 	}
-	return slices, nil
+	return blocks, nil
 }
 
 type visit struct {
@@ -100,44 +398,85 @@ type visit struct {
 	next *visit
 }
 
-func (m *Marshaler) marshalSlice(v reflect.Value) (string, error) {
-	if v.IsNil() || v.Len() == 0 {
-		return "", nil
+// seen reports whether the struct v was visited already, otherwise v is
+// added to visited. Values which are not addressable are never seen.
+func seen(v reflect.Value, visited map[uintptr]*visit) bool {
+	if !v.CanAddr() {
+		return false
+	}
+	addr := v.UnsafeAddr()
+	typ := v.Type()
+	first := visited[addr]
+	for p := first; p != nil; p = p.next {
+		if p.addr == addr && p.typ == typ {
+			return true
+		}
 	}
+	visited[addr] = &visit{addr, typ, first}
+	return false
+}
 
-	res := ""
+// marshalSlice flattens the elements of the top-level slice v to a block.
+// ctx is checked between rows.
+func (m *Marshaler) marshalSlice(ctx context.Context, v reflect.Value) (*block, error) {
+	if v.IsNil() || v.Len() == 0 || m.used.exceeded {
+		return nil, nil
+	}
+
+	b := &block{}
 	// header
 	first := followPtr(v.Index(0))
 	if first.Type().Kind() != reflect.Struct {
-		return "", fmt.Errorf("top-level slice with non struct type: %s", v.Index(0).Type().Kind())
+		return nil, fmt.Errorf("top-level slice with non struct type: %s", v.Index(0).Type().Kind())
+	}
+	b.header = m.marshal(first, true, map[uintptr]*visit{}, "")
+	if !m.NoHeader && !m.admit(b, b.header, true) {
+		if !dropHeader(b) {
+			return nil, nil
+		}
+		return b, nil
 	}
-	if !m.NoHeader {
-		header := m.marshal(first, true, map[uintptr]*visit{})
-		res = res + fmt.Sprintf("%s%s", strings.Join(header, m.FieldDelim), m.RowDelim)
+
+	for _, i := range m.order(v) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		row := m.marshal(v.Index(i), false, map[uintptr]*visit{}, "")
+		if !m.admit(b, row, false) {
+			break
+		}
+		b.rows = append(b.rows, row)
 	}
+	return b, nil
+}
 
-	for i := 0; i < v.Len(); i++ {
-		row := m.marshal(v.Index(i), false, map[uintptr]*visit{})
+// render joins the header (if NoHeader option is false) and the rows of b to csv.
+func (m *Marshaler) render(b block) string {
+	res := ""
+	if m.BlockTitle {
+		res = res + fmt.Sprintf("# %s%s", b.title(), m.RowDelim)
+	}
+	if !m.NoHeader && b.header != nil {
+		res = res + fmt.Sprintf("%s%s", strings.Join(b.header, m.FieldDelim), m.RowDelim)
+	}
+	for _, row := range b.rows {
 		res = res + fmt.Sprintf("%s%s", strings.Join(row, m.FieldDelim), m.RowDelim)
 	}
-	return res, nil
+	if b.truncated {
+		res = res + m.TruncateMarker + m.RowDelim
+	}
+	return res
 }
 
-func (m *Marshaler) marshal(v reflect.Value, header bool, visited map[uintptr]*visit) []string {
+// marshal flattens the struct v to its header or row cells. path is the
+// field path of v (e.g. 'Inner') used to look up column options.
+func (m *Marshaler) marshal(v reflect.Value, header bool, visited map[uintptr]*visit, path string) []string {
 	res := []string{}
 	v = followPtr(v)
 
 	// break recursion
-	if v.CanAddr() {
-		addr := v.UnsafeAddr()
-		typ := v.Type()
-		seen := visited[addr]
-		for p := seen; p != nil; p = p.next {
-			if p.addr == addr && p.typ == typ {
-				return res
-			}
-		}
-		visited[addr] = &visit{addr, typ, seen}
+	if seen(v, visited) {
+		return res
 	}
 
 	for i := 0; i < v.NumField(); i++ {
@@ -147,60 +486,135 @@ func (m *Marshaler) marshal(v reflect.Value, header bool, visited map[uintptr]*v
 		if strings.ToLower(string(typ.Name[0])) == string(typ.Name[0]) || strings.HasPrefix(typ.Name, "XXX_") {
 			continue
 		}
+		p := fieldPath(path, typ)
+
+		if m.deep(p, typ, val) {
+			res = append(res, m.marshalDeep(v.Type(), p, typ, val, header, visited)...)
+			continue
+		}
+		if m.embedJSON(p, typ, val) {
+			switch {
+			case header:
+				res = append(res, m.header(v.Type(), p, typ))
+			case val.Kind() == reflect.Ptr && val.IsNil():
+				res = append(res, m.Null)
+			default:
+				res = append(res, m.cell(p, typ, m.json(val, visited)))
+			}
+			continue
+		}
 
 		switch val.Kind() {
 		case reflect.Map:
 			if header {
-				res = append(res, name(typ))
+				res = append(res, m.header(v.Type(), p, typ))
+			} else if val.Len() == 0 {
+				res = append(res, m.Empty)
 			} else {
 				s := []string{}
 				for _, k := range val.MapKeys() {
 					// k: struct keys are not supported so far
-					if val.MapIndex(k).Kind() == reflect.Struct {
+					e := val.MapIndex(k)
+					if m.flatten(p, typ, e) {
 						s = append(s, fmt.Sprintf("%s:%s",
 							fmt.Sprintf("%v", k),
-							strings.Join(m.marshal(val.MapIndex(k), header, visited), m.InnerDelim),
+							strings.Join(m.marshal(e, header, visited, p), m.InnerDelim),
 						))
 					} else {
 						s = append(s, fmt.Sprintf("%s:%s",
 							fmt.Sprintf("%v", k),
-							m.Printf("%v", val.MapIndex(k)),
+							m.value(p, typ, e),
 						))
 					}
 				}
-				res = append(res, strings.Join(s, m.InnerDelim))
+				res = append(res, m.cell(p, typ, strings.Join(s, m.InnerDelim)))
 			}
 		case reflect.Slice:
-			if header {
-				res = append(res, name(typ))
+			if val.Type().Elem().Kind() == reflect.Uint8 {
+				// bytes
+				if header {
+					res = append(res, m.header(v.Type(), p, typ))
+				} else {
+					res = append(res, m.cell(p, typ, m.value(p, typ, val)))
+				}
+			} else if header {
+				res = append(res, m.header(v.Type(), p, typ))
+			} else if val.Len() == 0 {
+				res = append(res, m.Empty)
 			} else {
 				s := []string{}
 				for j := 0; j < val.Len(); j++ {
-					if val.Index(j).Kind() == reflect.Struct {
-						s = append(s, m.marshal(val.Index(j), header, visited)...)
+					e := val.Index(j)
+					if m.flatten(p, typ, e) {
+						s = append(s, m.marshal(e, header, visited, p)...)
 					} else {
-						s = append(s, m.Printf("%v", val.Index(j)))
+						s = append(s, m.value(p, typ, e))
 					}
 				}
-				res = append(res, strings.Join(s, m.InnerDelim))
+				res = append(res, m.cell(p, typ, strings.Join(s, m.InnerDelim)))
 			}
-		case reflect.Struct:
-			res = append(res, m.marshal(val, header, visited)...)
-		case reflect.Ptr:
-			if val.Elem().Kind() == reflect.Struct {
-				res = append(res, m.marshal(val, header, visited)...)
+		case reflect.Struct, reflect.Ptr:
+			if m.SplitMoney && protoFullName(val.Type()) == "google.type.Money" {
+				if header {
+					h := m.header(v.Type(), p, typ)
+					res = append(res, h, h+"Currency")
+				} else {
+					res = append(res, m.marshalMoney(p, typ, val)...)
+				}
+			} else if m.formatter(p, typ, val.Type()) != nil {
+				if header {
+					res = append(res, m.header(v.Type(), p, typ))
+				} else {
+					res = append(res, m.cell(p, typ, m.value(p, typ, val)))
+				}
+			} else if val.Kind() == reflect.Struct || !val.IsNil() && val.Elem().Kind() == reflect.Struct {
+				res = append(res, m.marshal(val, header, visited, p)...)
+			} else if val.Type().Elem().Kind() == reflect.Struct {
+				res = append(res, m.marshalNull(val.Type().Elem(), header, visited, p)...)
+			} else if header {
+				res = append(res, m.header(v.Type(), p, typ))
+			} else if val.IsNil() {
+				// unset optional scalar
+				res = append(res, m.Unset)
+			} else {
+				res = append(res, m.cell(p, typ, m.value(p, typ, val.Elem())))
 			}
 		default:
 			if header {
-				res = append(res, name(typ))
+				res = append(res, m.header(v.Type(), p, typ))
 			} else {
-				res = append(res, m.Printf("%v", val))
+				res = append(res, m.cell(p, typ, m.value(p, typ, val)))
 			}
 		}
 	}
 	return res
 }
 
+// marshalNull flattens a nil pointer to the struct type t. Each column of
+// t is rendered as m.Null. Nil pointers of recursive types are omitted as
+// their columns are unbounded, unless bounded by MaxDepth.
+func (m *Marshaler) marshalNull(t reflect.Type, header bool, visited map[uintptr]*visit, path string) []string {
+	if recursive(t) && m.MaxDepth <= 0 {
+		return nil
+	}
+	res := m.marshal(reflect.New(t).Elem(), header, visited, path)
+	if !header {
+		for i := range res {
+			res[i] = m.Null
+		}
+	}
+	return res
+}
+
+// flatten reports whether the element v of the slice or map field f with
+// path p is a struct flattened into the cell (no formatter registered).
+func (m *Marshaler) flatten(p string, f reflect.StructField, v reflect.Value) bool {
+	if followPtr(v).Kind() != reflect.Struct {
+		return false
+	}
+	return m.formatter(p, f, v.Type()) == nil
+}
+
 // name evaluates field name to use when marshaling. The following order applies:
 // 1. csv tag
 // 2. field name
@@ -220,7 +634,31 @@ func followPtr(v reflect.Value) reflect.Value {
 	return v
 }
 
-// ContentType returns 'text/csv'.
+// extension returns the file extension matching ContentType.
+func (m *Marshaler) extension() string {
+	ext := ".csv"
+	if m.Packaging == PackZip {
+		ext = ".zip"
+	}
+	if m.Gzip {
+		ext += ".gz"
+	}
+	return ext
+}
+
+// ContentType returns 'text/csv', 'application/zip' or 'multipart/mixed'
+// depending on the Packaging option. The charset parameter is set
+// according to the Encoding option. With the Gzip option 'application/gzip'
+// is returned.
 func (m *Marshaler) ContentType(v interface{}) string {
-	return "text/csv"
+	if m.Gzip {
+		return "application/gzip"
+	}
+	switch m.Packaging {
+	case PackZip:
+		return "application/zip"
+	case PackMultipart:
+		return "multipart/mixed; boundary=" + m.boundary()
+	}
+	return m.withCharset("text/csv")
 }
@@ -1,12 +1,15 @@
 package csv
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 
 	"github.com/kylelemons/godebug/pretty"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 type outer struct {
@@ -99,7 +102,7 @@ func TestMarshaler_Marshal(t *testing.T) {
 				a: []string{"a", "b"},
 				b: []string{"c", "d"},
 			},
-			wantErr: true,
+			want: "",
 		},
 		{
 			name: "multiple slices w/ struct",
@@ -232,7 +235,7 @@ func TestMarshaler_MarshalLanguage(t *testing.T) {
 				a: []string{"a", "b"},
 				b: []string{"c", "d"},
 			},
-			wantErr: true,
+			want: "",
 		},
 		{
 			name: "multiple slices w/ struct",
@@ -286,3 +289,119 @@ func TestMarshaler_MarshalLanguage(t *testing.T) {
 		})
 	}
 }
+
+func TestMarshaler_MarshalBlocks(t *testing.T) {
+
+	v := struct {
+		A []inner `csv:"first"`
+		B []inner
+	}{
+		A: []inner{{Col3: "a", Col4: 1}},
+		B: []inner{{Col3: "b", Col4: 2}},
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		v    interface{}
+		want string
+	}{
+		{
+			name: "block title",
+			m:    &Marshaler{BlockTitle: true},
+			v:    v,
+			want: "# first\nCol3;Col4;Col5\na;1;0\n---\n# B\nCol3;Col4;Col5\nb;2;0\n",
+		},
+		{
+			name: "section column and block delimiter",
+			m:    &Marshaler{SectionColumn: true, BlockDelim: "\n"},
+			v:    v,
+			want: "section;Col3;Col4;Col5\nfirst;a;1;0\n\nsection;Col3;Col4;Col5\nB;b;2;0\n",
+		},
+		{
+			name: "top-level slice",
+			m:    &Marshaler{BlockTitle: true, SectionColumn: true},
+			v:    v.A,
+			want: "# data\nsection;Col3;Col4;Col5\ndata;a;1;0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(tt.v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+type nullable struct {
+	Name     *wrapperspb.StringValue
+	Count    *int64
+	Tags     []string
+	Labels   map[string]string
+	Children []*inner
+}
+
+func TestMarshaler_MarshalNull(t *testing.T) {
+
+	count := int64(0)
+	v := []nullable{
+		{
+			Name:   wrapperspb.String("a"),
+			Count:  &count,
+			Tags:   []string{"x"},
+			Labels: map[string]string{"k": "v"},
+		},
+		{},
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		want string
+	}{
+		{
+			name: "defaults",
+			m:    &Marshaler{},
+			want: "Value;Count;Tags;Labels;Children\na;0;x;k:v;\n;;;;\n",
+		},
+		{
+			name: "postgres copy",
+			m:    &Marshaler{Null: `\N`, Unset: `\N`, Empty: "{}"},
+			want: "Value;Count;Tags;Labels;Children\na;0;x;k:v;{}\n\\N;\\N;{};{};{}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshaler_MarshalContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	v := []inner{{Col3: "a"}, {Col3: "b"}}
+	for _, m := range []interface {
+		MarshalContext(ctx context.Context, i interface{}) ([]byte, error)
+	}{&Marshaler{}, &TableMarshaler{}} {
+		if _, err := m.MarshalContext(ctx, v); !errors.Is(err, context.Canceled) {
+			t.Errorf("%T.MarshalContext() error = %v, want %v", m, err, context.Canceled)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrMaxDepth is returned with RecursionError for values nested deeper than MaxDepth.
+var ErrMaxDepth = errors.New("max depth exceeded")
+
+// Recursion specifies how messages nested deeper than MaxDepth are rendered.
+type Recursion int
+
+const (
+	// RecursionTruncate omits the columns of messages nested deeper than MaxDepth.
+	RecursionTruncate Recursion = iota
+	// RecursionJSON renders messages nested deeper than MaxDepth as compact JSON to one cell.
+	RecursionJSON
+	// RecursionError omits the columns like RecursionTruncate but returns ErrMaxDepth
+	// if a row contains a message nested deeper than MaxDepth.
+	RecursionError
+)
+
+// depth returns the nesting level of the field path p, top-level fields
+// have depth 1.
+func depth(p string) int {
+	return strings.Count(p, ".") + 1
+}
+
+// nested reports whether values of type t of the field f with path p are
+// flattened to own columns or cells, i.e. t is a struct or a slice / map
+// of structs without registered formatter.
+func (m *Marshaler) nested(p string, f reflect.StructField, t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map:
+		t = t.Elem()
+	}
+	return followPtrType(t).Kind() == reflect.Struct && m.formatter(p, f, t) == nil
+}
+
+// deep reports whether the value v of field f with path p is a message
+// whose columns are nested deeper than MaxDepth.
+func (m *Marshaler) deep(p string, f reflect.StructField, v reflect.Value) bool {
+	// the columns of v are one level below p
+	return m.MaxDepth > 0 && depth(p)+1 > m.MaxDepth && m.nested(p, f, v.Type())
+}
+
+// marshalDeep renders the value v of field f with path p, which is nested
+// deeper than MaxDepth, according to the Recursion option. t is the type
+// of the struct containing f.
+func (m *Marshaler) marshalDeep(t reflect.Type, p string, f reflect.StructField, v reflect.Value, header bool, visited map[uintptr]*visit) []string {
+	switch m.Recursion {
+	case RecursionJSON:
+		switch {
+		case header:
+			return []string{m.header(t, p, f)}
+		case v.Kind() == reflect.Ptr && v.IsNil():
+			return []string{m.Null}
+		}
+		return []string{m.cell(p, f, m.json(v, visited))}
+	case RecursionError:
+		if !header && m.err == nil && !empty(v) {
+			m.err = fmt.Errorf("%w: %s", ErrMaxDepth, p)
+		}
+	}
+	return nil
+}
+
+// empty reports whether v is a nil pointer, an empty slice / map or a zero struct.
+func empty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,85 @@
+package csv
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+type node struct {
+	Name  string
+	Left  *node
+	Right *node
+}
+
+func TestMarshaler_MarshalMaxDepth(t *testing.T) {
+
+	tree := []node{
+		{Name: "a", Left: &node{Name: "b", Left: &node{Name: "c"}}},
+		{Name: "d"},
+	}
+
+	cyclic := []outer{{Col1: "a", Inner: inner{Col3: "b"}}}
+	cyclic[0].Inner.Outer = &cyclic[0] // introduce cycle
+
+	tests := []struct {
+		name    string
+		m       *Marshaler
+		v       interface{}
+		want    string
+		wantErr error
+	}{
+		{
+			name: "truncate",
+			m:    &Marshaler{MaxDepth: 2, Null: "-"},
+			v:    tree,
+			want: "Name;Name;Name\na;b;-\nd;-;-\n",
+		},
+		{
+			name: "json",
+			m:    &Marshaler{MaxDepth: 2, Recursion: RecursionJSON, Null: "-"},
+			v:    tree,
+			want: "Name;Name;Left;Right;Name;Left;Right\n" +
+				"a;b;{\"Name\":\"c\",\"Left\":null,\"Right\":null};-;-;-;-\n" +
+				"d;-;-;-;-;-;-\n",
+		},
+		{
+			name: "documented depth",
+			m:    &Marshaler{MaxDepth: 2},
+			v:    []level1{{Inner: level2{Col3: "a", Deep: &level3{Col3: "b"}}}},
+			want: "Col3\na\n",
+		},
+		{
+			name: "json with cycle",
+			m:    &Marshaler{MaxDepth: 2, Recursion: RecursionJSON, Null: "-"},
+			v:    cyclic,
+			want: "Col1;Col2;slice;map1;map2;Col3;Col4;Col5;Outer;InnerSlice\na;;;;;b;0;0;null;\n",
+		},
+		{
+			name: "error within depth",
+			m:    &Marshaler{MaxDepth: 3, Recursion: RecursionError, Null: "-"},
+			v:    tree,
+			want: "Name;Name;Name;Name;Name;Name;Name\na;b;c;-;-;-;-\nd;-;-;-;-;-;-\n",
+		},
+		{
+			name:    "error",
+			m:       &Marshaler{MaxDepth: 2, Recursion: RecursionError},
+			v:       tree,
+			wantErr: ErrMaxDepth,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(tt.v)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CSVMarshaler.Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package csv
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// encode converts the rendered UTF-8 string s to the configured Encoding.
+// Characters not representable are replaced by '?' unless StrictEncoding
+// is set.
+func (m *Marshaler) encode(s string) ([]byte, error) {
+	if m.Encoding == nil {
+		return []byte(s), nil
+	}
+	e := m.Encoding.NewEncoder()
+	if !m.StrictEncoding {
+		repl, err := m.Encoding.NewEncoder().Bytes([]byte("?"))
+		if err != nil {
+			return nil, err
+		}
+		e = &encoding.Encoder{Transformer: replaceUnsupported{e, repl}}
+	}
+	return e.Bytes([]byte(s))
+}
+
+// replaceUnsupported replaces the characters not representable by the
+// encoder with repl. Unlike encoding.ReplaceUnsupported, which writes the
+// ASCII substitute control character, repl is visible in spreadsheets.
+type replaceUnsupported struct {
+	transform.Transformer
+	repl []byte
+}
+
+// repertoireError is implemented by the errors of encoders for characters
+// not representable (see encoding.ReplaceUnsupported).
+type repertoireError interface {
+	error
+	Replacement() byte
+}
+
+func (r replaceUnsupported) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	nDst, nSrc, err = r.Transformer.Transform(dst, src, atEOF)
+	for err != nil {
+		if _, ok := err.(repertoireError); !ok {
+			return nDst, nSrc, err
+		}
+		if len(dst)-nDst < len(r.repl) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		nDst += copy(dst[nDst:], r.repl)
+		_, size := utf8.DecodeRune(src[nSrc:])
+		nSrc += size
+		err = nil
+		if nSrc < len(src) {
+			var dn, sn int
+			dn, sn, err = r.Transformer.Transform(dst[nDst:], src[nSrc:], atEOF)
+			nDst += dn
+			nSrc += sn
+		}
+	}
+	return nDst, nSrc, err
+}
+
+// charset returns the charset parameter of the content type matching the
+// configured Encoding or an empty string if the output is plain UTF-8.
+func (m *Marshaler) charset() string {
+	if m.Encoding == nil {
+		return ""
+	}
+	if m.Encoding == unicode.UTF8BOM {
+		return "utf-8"
+	}
+	n, err := ianaindex.MIME.Name(m.Encoding)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(n)
+}
+
+// withCharset appends the charset parameter to the content type ct.
+func (m *Marshaler) withCharset(ct string) string {
+	if c := m.charset(); c != "" {
+		return ct + "; charset=" + c
+	}
+	return ct
+}
@@ -0,0 +1,92 @@
+package csv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestMarshaler_MarshalEncoding(t *testing.T) {
+
+	v := []inner{{Col3: "Größe €"}}
+
+	tests := []struct {
+		name            string
+		m               *Marshaler
+		v               interface{}
+		want            string
+		wantContentType string
+		wantErr         bool
+	}{
+		{
+			name:            "utf-8",
+			m:               &Marshaler{NoHeader: true},
+			v:               v,
+			want:            "Größe €;0;0\n",
+			wantContentType: "text/csv",
+		},
+		{
+			name:            "utf-8 with bom",
+			m:               &Marshaler{NoHeader: true, Encoding: unicode.UTF8BOM},
+			v:               v,
+			want:            "\xef\xbb\xbfGröße €;0;0\n",
+			wantContentType: "text/csv; charset=utf-8",
+		},
+		{
+			name:            "utf-16le with bom",
+			m:               &Marshaler{NoHeader: true, FieldDelim: "\t", Encoding: unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)},
+			v:               []inner{{Col3: "ä"}},
+			want:            "\xff\xfe\xe4\x00\t\x000\x00\t\x000\x00\n\x00",
+			wantContentType: "text/csv; charset=utf-16",
+		},
+		{
+			name:            "windows-1252",
+			m:               &Marshaler{NoHeader: true, Encoding: charmap.Windows1252},
+			v:               v,
+			want:            "Gr\xf6\xdfe \x80;0;0\n",
+			wantContentType: "text/csv; charset=windows-1252",
+		},
+		{
+			name:            "unrepresentable replaced",
+			m:               &Marshaler{NoHeader: true, Encoding: charmap.ISO8859_1},
+			v:               v,
+			want:            "Gr\xf6\xdfe ?;0;0\n",
+			wantContentType: "text/csv; charset=iso-8859-1",
+		},
+		{
+			name:            "unrepresentable replaced beyond buffer",
+			m:               &Marshaler{NoHeader: true, Encoding: charmap.ISO8859_1},
+			v:               []inner{{Col3: strings.Repeat("€ä", 5000)}},
+			want:            strings.Repeat("?\xe4", 5000) + ";0;0\n",
+			wantContentType: "text/csv; charset=iso-8859-1",
+		},
+		{
+			name:    "unrepresentable strict",
+			m:       &Marshaler{NoHeader: true, Encoding: charmap.ISO8859_1, StrictEncoding: true},
+			v:       v,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CSVMarshaler.Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+			if got := tt.m.ContentType(nil); got != tt.wantContentType {
+				t.Errorf("Marshaler.ContentType() = %v, want %v", got, tt.wantContentType)
+			}
+		})
+	}
+}
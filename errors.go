@@ -0,0 +1,40 @@
+package csv
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// errorStatus returns the status proto of error responses. Those are marshaled
+// by the gateway error handler (*status.Status) or the stream error handler
+// (map with key 'error').
+func errorStatus(i interface{}) (*status.Status, bool) {
+	switch v := i.(type) {
+	case *status.Status:
+		return v, v != nil
+	case map[string]proto.Message:
+		s, ok := v["error"].(*status.Status)
+		return s, ok && s != nil
+	}
+	return nil, false
+}
+
+// marshalStatus flattens the error status s to a block containing the
+// code, the message and the details rendered as JSON (or their type URL
+// if the type is unknown). Message and details are sanitised as they may
+// echo user input.
+func (m *Marshaler) marshalStatus(s *status.Status) block {
+	details := ""
+	for i, d := range s.GetDetails() {
+		if i > 0 {
+			details += m.InnerDelim
+		}
+		details += anyJSON(d)
+	}
+	return block{
+		name:   "error",
+		header: []string{"code", "message", "details"},
+		rows:   [][]string{{codes.Code(s.GetCode()).String(), m.Sanitize.apply(s.GetMessage()), m.Sanitize.apply(details)}},
+	}
+}
@@ -0,0 +1,58 @@
+package csv
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMarshaler_MarshalStatus(t *testing.T) {
+
+	st, err := grpcstatus.New(codes.NotFound, "foo not found").WithDetails(&errdetails.ResourceInfo{ResourceName: "foo"})
+	if err != nil {
+		t.Fatalf("status.WithDetails() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		v    interface{}
+		want string
+	}{
+		{
+			name: "error handler",
+			m:    &Marshaler{},
+			v:    grpcstatus.New(codes.InvalidArgument, "bad").Proto(),
+			want: "code;message;details\nInvalidArgument;bad;\n",
+		},
+		{
+			name: "stream error handler with details",
+			m:    &Marshaler{},
+			v:    map[string]proto.Message{"error": st.Proto()},
+			want: "code;message;details\nNotFound;foo not found;{\"@type\":\"type.googleapis.com/google.rpc.ResourceInfo\",\"resourceName\":\"foo\"}\n",
+		},
+		{
+			name: "sanitised message",
+			m:    &Marshaler{Sanitize: SanitizeQuote},
+			v:    grpcstatus.New(codes.InvalidArgument, `=HYPERLINK("x")`).Proto(),
+			want: "code;message;details\nInvalidArgument;'=HYPERLINK(\"x\");\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(tt.v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
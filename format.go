@@ -0,0 +1,297 @@
+package csv
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Formatter renders the value v to a cell. printf is the Printf func of the
+// Marshaler which might be used for locale aware formatting.
+//
+// Formatters registered for structs (e.g. proto messages) render the
+// whole struct into one cell instead of flattening it.
+type Formatter func(v interface{}, printf func(format string, a ...any) string) string
+
+// formatter returns the formatter for values of type t of the field f with
+// path p. The following order applies:
+// 1. Formatter of ColumnOptions
+// 2. ProtoFormatters
+// 3. TypeFormatters
+// 4. built-in formatters of well-known types
+// If none is registered nil is returned.
+func (m *Marshaler) formatter(p string, f reflect.StructField, t reflect.Type) Formatter {
+	if o := m.column(p, name(f)); o.Formatter != nil {
+		return o.Formatter
+	}
+	if n := protoFullName(t); n != "" {
+		if fm, ok := m.ProtoFormatters[n]; ok {
+			return fm
+		}
+	}
+	if fm, ok := m.TypeFormatters[t]; ok {
+		return fm
+	}
+	if t.Kind() == reflect.Ptr {
+		if fm, ok := m.TypeFormatters[t.Elem()]; ok {
+			return fm
+		}
+	}
+	return m.builtin(t)
+}
+
+// value renders the single value v of field f with path p using the
+// registered formatter falling back to Printf.
+func (m *Marshaler) value(p string, f reflect.StructField, v reflect.Value) string {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return m.Null
+	}
+	if fm := m.formatter(p, f, v.Type()); fm != nil && v.CanInterface() {
+		return fm(v.Interface(), m.Printf)
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		return m.Bytes.encode(v.Bytes())
+	}
+	if v.Kind() == reflect.Bool {
+		bf := m.Bool
+		if o := m.column(p, name(f)); o.Bool != (BoolFormat{}) {
+			bf = o.Bool
+		}
+		return bf.format(m.Printf, v)
+	}
+	if k := v.Kind(); k == reflect.Float32 || k == reflect.Float64 {
+		ff := m.Float
+		if o := m.column(p, name(f)); o.Float != (FloatFormat{}) {
+			ff = o.Float
+		}
+		return ff.format(m.Printf, v)
+	}
+	if k := v.Kind(); k == reflect.Int64 || k == reflect.Uint64 {
+		i64 := m.Int64
+		if o := m.column(p, name(f)); o.Int64 != Int64Default {
+			i64 = o.Int64
+		}
+		if i64 > Int64Number {
+			return i64.text(v)
+		}
+	}
+	return m.Printf("%v", v)
+}
+
+// Int64 specifies how 64-bit integers are rendered.
+type Int64 int
+
+const (
+	// Int64Default renders plain numbers. Used in ColumnOptions it inherits Marshaler.Int64.
+	Int64Default Int64 = iota
+	// Int64Number renders plain numbers.
+	Int64Number
+	// Int64Formula renders a text formula (="123").
+	Int64Formula
+	// Int64Apostrophe renders the number prefixed with a single quote ('123).
+	Int64Apostrophe
+	// Int64Quoted renders the number as quoted string ("123") like protojson.
+	Int64Quoted
+)
+
+// text renders the integer v as text. The number is not localised as
+// 64-bit integers are usually identifiers.
+func (i Int64) text(v reflect.Value) string {
+	var s string
+	if v.Kind() == reflect.Uint64 {
+		s = strconv.FormatUint(v.Uint(), 10)
+	} else {
+		s = strconv.FormatInt(v.Int(), 10)
+	}
+	switch i {
+	case Int64Formula:
+		return `="` + s + `"`
+	case Int64Apostrophe:
+		return "'" + s
+	case Int64Quoted:
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// BoolFormat specifies the rendering of booleans. The values are passed
+// to Printf as format, so they are localised by a message catalog.
+type BoolFormat struct {
+	True  string
+	False string
+}
+
+var (
+	// BoolOneZero renders booleans as '1' and '0'.
+	BoolOneZero = BoolFormat{True: "1", False: "0"}
+	// BoolYesNo renders booleans as 'Yes' and 'No'.
+	BoolYesNo = BoolFormat{True: "Yes", False: "No"}
+)
+
+func (bf BoolFormat) format(printf func(format string, a ...any) string, v reflect.Value) string {
+	if bf == (BoolFormat{}) {
+		return printf("%v", v)
+	}
+	if v.Bool() {
+		return printf(bf.True)
+	}
+	return printf(bf.False)
+}
+
+// Parse parses the cell s rendered using bf. printf is the Printf of the
+// Marshaler used to localise the values, nil if not localised.
+func (bf BoolFormat) Parse(printf func(format string, a ...any) string, s string) (bool, error) {
+	if printf == nil {
+		printf = fmt.Sprintf
+	}
+	if bf == (BoolFormat{}) {
+		return strconv.ParseBool(s)
+	}
+	switch s {
+	case printf(bf.True):
+		return true, nil
+	case printf(bf.False):
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean: %q", s)
+}
+
+// FloatFormat specifies the formatting of floating point values.
+type FloatFormat struct {
+	// Format is the format passed to strconv.FormatFloat: 'f' (no exponent),
+	// 'e' (exponent) or 'g' (exponent for large exponents only).
+	// Zero renders values using Printf("%v").
+	Format byte
+	// Precision is the number of digits (decimals for 'f' and 'e'). Values are
+	// rounded and localised by Printf. -1 renders the shortest representation
+	// that round-trips, which is not localised.
+	Precision int
+
+	// NaN renders not-a-number values. Defaults to Printf("%v").
+	NaN string
+	// PosInf renders positive infinity. Defaults to Printf("%v").
+	PosInf string
+	// NegInf renders negative infinity. Defaults to Printf("%v").
+	NegInf string
+}
+
+// ProtoJSONFloat formats floats compatible to protojson: shortest
+// round-trip representation and 'NaN', 'Infinity' and '-Infinity'.
+var ProtoJSONFloat = FloatFormat{Format: 'g', Precision: -1, NaN: "NaN", PosInf: "Infinity", NegInf: "-Infinity"}
+
+func (ff FloatFormat) format(printf func(format string, a ...any) string, v reflect.Value) string {
+	f := v.Float()
+	switch {
+	case math.IsNaN(f) && ff.NaN != "":
+		return ff.NaN
+	case math.IsInf(f, 1) && ff.PosInf != "":
+		return ff.PosInf
+	case math.IsInf(f, -1) && ff.NegInf != "":
+		return ff.NegInf
+	case ff.Format == 0 || math.IsNaN(f) || math.IsInf(f, 0):
+		return printf("%v", v)
+	case ff.Precision < 0:
+		return strconv.FormatFloat(f, ff.Format, -1, v.Type().Bits())
+	}
+	return printf(fmt.Sprintf("%%.%d%c", ff.Precision, ff.Format), f)
+}
+
+// followPtrType returns the element type of pointer types.
+func followPtrType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// Bytes specifies the encoding of bytes fields.
+type Bytes int
+
+const (
+	// BytesBase64 encodes bytes using standard base64 (like protojson).
+	BytesBase64 Bytes = iota
+	// BytesBase64URL encodes bytes using URL-safe base64.
+	BytesBase64URL
+	// BytesHex encodes bytes using lower case hex.
+	BytesHex
+)
+
+func (e Bytes) encode(b []byte) string {
+	switch e {
+	case BytesBase64URL:
+		return base64.URLEncoding.EncodeToString(b)
+	case BytesHex:
+		return hex.EncodeToString(b)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// Decode decodes the cell s rendered using the encoding e.
+func (e Bytes) Decode(s string) ([]byte, error) {
+	switch e {
+	case BytesBase64URL:
+		return base64.URLEncoding.DecodeString(s)
+	case BytesHex:
+		return hex.DecodeString(s)
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// protoFullName returns the full name of the proto message type t (or *t)
+// or an empty name if t is no proto message.
+func protoFullName(t reflect.Type) protoreflect.FullName {
+	if t.Kind() != reflect.Ptr {
+		t = reflect.PtrTo(t)
+	}
+	if t.Elem().Kind() != reflect.Struct || !t.Implements(protoMessage) {
+		return ""
+	}
+	return reflect.New(t.Elem()).Interface().(proto.Message).ProtoReflect().Descriptor().FullName()
+}
+
+var protoMessage = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// recursiveTypes caches the result of recursive per type.
+var recursiveTypes sync.Map
+
+// recursive reports whether the struct type t contains itself (through
+// pointers, slices or maps).
+func recursive(t reflect.Type) bool {
+	if r, ok := recursiveTypes.Load(t); ok {
+		return r.(bool)
+	}
+	r := reaches(t, t, map[reflect.Type]bool{})
+	recursiveTypes.Store(t, r)
+	return r
+}
+
+// reaches reports whether the type target is reachable from the fields of t.
+func reaches(t, target reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		ft := t.Field(i).Type
+		for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array || ft.Kind() == reflect.Map {
+			ft = ft.Elem()
+		}
+		if ft == target || reaches(ft, target, seen) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,379 @@
+package csv
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type formatted struct {
+	Price    float64
+	Ratio    float64
+	Prices   []float64
+	Timeout  *durationpb.Duration
+	Timeouts []*durationpb.Duration
+	Inner    inner
+}
+
+func TestMarshaler_MarshalFormatters(t *testing.T) {
+
+	v := []formatted{
+		{
+			Price:    1.5,
+			Ratio:    0.25,
+			Prices:   []float64{1, 2.25},
+			Timeout:  durationpb.New(2 * time.Second),
+			Timeouts: []*durationpb.Duration{durationpb.New(time.Minute)},
+			Inner:    inner{Col3: "a", Col5: 1234.5},
+		},
+		{},
+	}
+
+	twoDecimals := func(v interface{}, printf func(format string, a ...any) string) string {
+		return printf("%.2f", v)
+	}
+	percent := func(v interface{}, printf func(format string, a ...any) string) string {
+		return printf("%.0f%%", v.(float64)*100)
+	}
+	scientific := func(v interface{}, printf func(format string, a ...any) string) string {
+		return printf("%e", v)
+	}
+	duration := func(v interface{}, printf func(format string, a ...any) string) string {
+		return v.(*durationpb.Duration).AsDuration().String()
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		v    interface{}
+		want string
+	}{
+		{
+			name: "type formatter",
+			v:    v[:1],
+			m: &Marshaler{
+				TypeFormatters: map[reflect.Type]Formatter{reflect.TypeOf(float64(0)): twoDecimals},
+			},
+			want: "Price;Ratio;Prices;Seconds;Nanos;Timeouts;Col3;Col4;Col5\n" +
+				"1.50;0.25;1.00|2.25;2;0;60|0;a;0;1234.50\n",
+		},
+		{
+			name: "proto and column formatters",
+			v:    v,
+			m: &Marshaler{
+				TypeFormatters:  map[reflect.Type]Formatter{reflect.TypeOf(float64(0)): twoDecimals},
+				ProtoFormatters: map[protoreflect.FullName]Formatter{"google.protobuf.Duration": duration},
+				Columns: map[string]ColumnOptions{
+					"Ratio":      {Formatter: percent},
+					"Inner.Col5": {Formatter: scientific},
+				},
+			},
+			want: "Price;Ratio;Prices;Timeout;Timeouts;Col3;Col4;Col5\n" +
+				"1.50;25%;1.00|2.25;2s;1m0s;a;0;1.234500e+03\n" +
+				"0.00;0%;;;;;0;0.000000e+00\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(tt.v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshaler_MarshalBytes(t *testing.T) {
+
+	v := []struct {
+		B  []byte
+		BS [][]byte
+		BM map[string][]byte
+	}{
+		{
+			B:  []byte{0xfb, 0xff, 0x01},
+			BS: [][]byte{{0x01}, {0x02}},
+			BM: map[string][]byte{"k": {0xff}},
+		},
+		{},
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		want string
+	}{
+		{
+			name: "base64",
+			m:    &Marshaler{},
+			want: "B;BS;BM\n+/8B;AQ==|Ag==;k:/w==\n;;\n",
+		},
+		{
+			name: "base64 sanitized",
+			m:    &Marshaler{Sanitize: SanitizeQuote},
+			want: "B;BS;BM\n'+/8B;AQ==|Ag==;k:/w==\n;;\n",
+		},
+		{
+			name: "base64 url",
+			m:    &Marshaler{Bytes: BytesBase64URL},
+			want: "B;BS;BM\n-_8B;AQ==|Ag==;k:_w==\n;;\n",
+		},
+		{
+			name: "hex",
+			m:    &Marshaler{Bytes: BytesHex},
+			want: "B;BS;BM\nfbff01;01|02;k:ff\n;;\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshaler_MarshalInt64(t *testing.T) {
+
+	id := uint64(18446744073709551615)
+	v := []struct {
+		ID     int64
+		Fixed  *uint64
+		Count  int64
+		Small  int32
+		Values []int64
+		Map    map[string]int64
+	}{
+		{
+			ID:     9007199254740993,
+			Fixed:  &id,
+			Count:  -2,
+			Small:  3,
+			Values: []int64{1, 2},
+			Map:    map[string]int64{"a": 4},
+		},
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		want string
+	}{
+		{
+			name: "plain",
+			m:    &Marshaler{NoHeader: true},
+			want: "9007199254740993;18446744073709551615;-2;3;1|2;a:4\n",
+		},
+		{
+			name: "formula",
+			m:    &Marshaler{NoHeader: true, Int64: Int64Formula, Sanitize: SanitizeQuote},
+			want: "=\"9007199254740993\";=\"18446744073709551615\";=\"-2\";3;=\"1\"|=\"2\";a:=\"4\"\n",
+		},
+		{
+			name: "column overrides",
+			m: &Marshaler{
+				NoHeader: true,
+				Int64:    Int64Apostrophe,
+				Columns: map[string]ColumnOptions{
+					"Fixed": {Int64: Int64Quoted},
+					"Count": {Int64: Int64Number},
+				},
+			},
+			want: "'9007199254740993;\"18446744073709551615\";-2;3;'1|'2;a:'4\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshaler_MarshalFloat(t *testing.T) {
+
+	a, b := 0.1, 0.2
+	v := []struct {
+		A float64
+		B float64
+		C float32
+		S []float64
+	}{
+		{A: a + b, B: 1e21, C: 0.1, S: []float64{math.NaN(), math.Inf(1), math.Inf(-1)}},
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		want string
+	}{
+		{
+			name: "printf",
+			m:    &Marshaler{NoHeader: true},
+			want: "0.30000000000000004;1e+21;0.1;NaN|+Inf|-Inf\n",
+		},
+		{
+			name: "fixed decimals",
+			m:    &Marshaler{NoHeader: true, Float: FloatFormat{Format: 'f', Precision: 2}},
+			want: "0.30;1000000000000000000000.00;0.10;NaN|+Inf|-Inf\n",
+		},
+		{
+			name: "shortest w/o exponent localised",
+			m: &Marshaler{
+				NoHeader: true,
+				Float:    FloatFormat{Format: 'f', Precision: -1},
+				Printf:   func(format string, a ...any) string { return message.NewPrinter(language.German).Sprintf(format, a...) },
+				Columns:  map[string]ColumnOptions{"A": {Float: FloatFormat{Format: 'f', Precision: 1}}},
+			},
+			want: "0,3;1000000000000000000000;0.1;NaN|∞|-∞\n",
+		},
+		{
+			name: "protojson",
+			m:    &Marshaler{NoHeader: true, Float: ProtoJSONFloat},
+			want: "0.30000000000000004;1e+21;0.1;NaN|Infinity|-Infinity\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshaler_MarshalBool(t *testing.T) {
+
+	v := []struct {
+		A  bool
+		B  *bool
+		S  []bool
+		M  map[string]bool
+		On bool
+	}{
+		{A: true, S: []bool{true, false}, M: map[string]bool{"k": false}},
+	}
+
+	c := catalog.NewBuilder()
+	c.SetString(language.German, "Yes", "Ja")
+	c.SetString(language.German, "No", "Nein")
+	p := message.NewPrinter(language.German, message.Catalog(c))
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		want string
+	}{
+		{
+			name: "printf",
+			m:    &Marshaler{NoHeader: true},
+			want: "true;;true|false;k:false;false\n",
+		},
+		{
+			name: "one zero with column override",
+			m:    &Marshaler{NoHeader: true, Bool: BoolOneZero, Columns: map[string]ColumnOptions{"On": {Bool: BoolFormat{"on", "off"}}}},
+			want: "1;;1|0;k:0;off\n",
+		},
+		{
+			name: "localised",
+			m:    &Marshaler{NoHeader: true, Bool: BoolYesNo, Printf: func(format string, a ...any) string { return p.Sprintf(format, a...) }},
+			want: "Ja;;Ja|Nein;k:Nein;Nein\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBytes_Decode(t *testing.T) {
+	b := []byte{0xfb, 0xff, 0x01}
+	for _, e := range []Bytes{BytesBase64, BytesBase64URL, BytesHex} {
+		got, err := e.Decode(e.encode(b))
+		if err != nil {
+			t.Errorf("Bytes(%d).Decode() error = %v", e, err)
+			continue
+		}
+		if diff := pretty.Compare(got, b); diff != "" {
+			t.Errorf("Bytes(%d).Decode() unexpected result:\n%s", e, diff)
+		}
+	}
+	if _, err := BytesHex.Decode("zz"); err == nil {
+		t.Errorf("Bytes.Decode() expected error for invalid input")
+	}
+}
+
+func TestBoolFormat_Parse(t *testing.T) {
+	c := catalog.NewBuilder()
+	c.SetString(language.German, "Yes", "Ja")
+	c.SetString(language.German, "No", "Nein")
+	p := message.NewPrinter(language.German, message.Catalog(c))
+	de := func(format string, a ...any) string { return p.Sprintf(format, a...) }
+
+	tests := []struct {
+		name    string
+		bf      BoolFormat
+		printf  func(format string, a ...any) string
+		s       string
+		want    bool
+		wantErr bool
+	}{
+		{name: "default", s: "true", want: true},
+		{name: "one zero", bf: BoolOneZero, s: "0", want: false},
+		{name: "localised", bf: BoolYesNo, printf: de, s: "Ja", want: true},
+		{name: "invalid", bf: BoolYesNo, s: "Ja", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.bf.Parse(tt.printf, tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BoolFormat.Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("BoolFormat.Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package csv
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultFilename is the attachment filename template used if Marshaler.Filename is empty.
+const DefaultFilename = "{{.Method}}{{.Ext}}"
+
+type requestKey struct{}
+
+type bindingsKey struct{}
+
+// Handler wraps the gateway mux h to make the request available to the
+// marshalers. Required by request specific options (e.g. Languages) in
+// addition to ForwardResponseOption.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := &bindings{resps: map[proto.Message]bool{}}
+		defer b.release()
+		ctx := context.WithValue(r.Context(), requestKey{}, r)
+		ctx = context.WithValue(ctx, bindingsKey{}, b)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// request returns the request stored in ctx by Handler.
+func request(ctx context.Context) (*http.Request, bool) {
+	r, ok := ctx.Value(requestKey{}).(*http.Request)
+	return r, ok
+}
+
+// contexts binds the request context to the response message between
+// ForwardResponseOption and Marshal.
+var contexts sync.Map
+
+// streamed returns the message of a server stream chunk, which the gateway
+// marshals wrapped in a 'result' map, or i.
+func streamed(i interface{}) interface{} {
+	if v, ok := i.(map[string]interface{}); ok && len(v) == 1 {
+		if resp, ok := v["result"].(proto.Message); ok {
+			return resp
+		}
+	}
+	return i
+}
+
+// bindings are the responses of one request bound to its context, which
+// are released when Handler returns.
+type bindings struct {
+	mu    sync.Mutex
+	resps map[proto.Message]bool
+}
+
+func (b *bindings) add(resp proto.Message) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.resps == nil {
+		// request finished
+		return false
+	}
+	b.resps[resp] = true
+	return true
+}
+
+func (b *bindings) remove(resp proto.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.resps, resp)
+}
+
+func (b *bindings) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for resp := range b.resps {
+		contexts.Delete(resp)
+	}
+	b.resps = nil
+}
+
+// bindContext binds ctx to resp until Marshal is called or the request
+// wrapped by Handler is finished.
+func bindContext(ctx context.Context, resp proto.Message) {
+	if b, ok := ctx.Value(bindingsKey{}).(*bindings); ok && b.add(resp) {
+		contexts.Store(resp, ctx)
+	}
+}
+
+// boundContext returns the context bound to the response i or the
+// background context.
+func boundContext(i interface{}) context.Context {
+	if resp, ok := streamed(i).(proto.Message); ok && resp != nil {
+		if ctx, ok := contexts.LoadAndDelete(resp); ok {
+			ctx := ctx.(context.Context)
+			if b, ok := ctx.Value(bindingsKey{}).(*bindings); ok {
+				b.remove(resp)
+			}
+			return ctx
+		}
+	}
+	return context.Background()
+}
+
+// ForwardResponseOption integrates the marshaler options requiring access
+// to the HTTP response or request into the gateway. It must be registered using
+//
+//	runtime.WithForwardResponseOption(m.ForwardResponseOption)
+//
+// The option is skipped for responses rendered by other marshalers.
+// Responses using a response_body selector are not bound to their request.
+func (m *Marshaler) ForwardResponseOption(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	return m.forwardResponse(ctx, w, resp, m.ContentType(resp), m.extension())
+}
+
+// ForwardResponseOption integrates the marshaler options requiring access
+// to the HTTP response into the gateway, see Marshaler.ForwardResponseOption.
+func (m *TableMarshaler) ForwardResponseOption(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	ext := ".txt"
+	if m.Gzip {
+		ext += ".gz"
+	}
+	return m.forwardResponse(ctx, w, resp, m.ContentType(resp), ext)
+}
+
+func (m *Marshaler) forwardResponse(ctx context.Context, w http.ResponseWriter, resp proto.Message, contentType, ext string) error {
+	// the Content-Type of server streams is not yet set for the first message
+	streamed := w.Header().Get("Transfer-Encoding") == "chunked" && w.Header().Get("Content-Type") == ""
+	if _, ok := request(ctx); ok && resp != nil && (streamed || w.Header().Get("Content-Type") == contentType) {
+		bindContext(ctx, resp)
+	}
+	if w.Header().Get("Content-Type") != contentType {
+		return nil
+	}
+	m.initDefaults()
+
+	if m.ScalarHeaderPrefix != "" {
+		for _, s := range m.scalars(resp) {
+			w.Header().Set(m.ScalarHeaderPrefix+s.header, s.value)
+		}
+	}
+	if m.Attachment {
+		name, err := m.filename(ctx, resp, ext)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+	return nil
+}
+
+// filename renders the Filename template. The service and method are
+// taken from the gateway context. If unavailable the method is derived
+// from the response message name (e.g. 'ListFoo' for 'ListFooResponse').
+func (m *Marshaler) filename(ctx context.Context, resp proto.Message, ext string) (string, error) {
+	t, err := template.New("filename").Parse(m.Filename)
+	if err != nil {
+		return "", err
+	}
+	data := struct {
+		Service string
+		Method  string
+		Ext     string
+	}{Ext: ext}
+	if method, ok := runtime.RPCMethod(ctx); ok {
+		// '/package.Service/Method'
+		s := strings.Split(strings.TrimPrefix(method, "/"), "/")
+		data.Method = s[len(s)-1]
+		if len(s) > 1 {
+			data.Service = s[0]
+		}
+	} else if resp != nil {
+		data.Method = strings.TrimSuffix(string(resp.ProtoReflect().Descriptor().Name()), "Response")
+	}
+
+	b := &strings.Builder{}
+	if err := t.Execute(b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,179 @@
+package csv
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/kylelemons/godebug/pretty"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/apipb"
+)
+
+type forwarder interface {
+	ContentType(v interface{}) string
+	ForwardResponseOption(ctx context.Context, w http.ResponseWriter, resp proto.Message) error
+}
+
+func TestMarshaler_ForwardResponseOptionAttachment(t *testing.T) {
+
+	req := httptest.NewRequest("GET", "/v1/foo", nil)
+	rctx, err := runtime.AnnotateContext(context.Background(), runtime.NewServeMux(), req, "/example.FooService/ListFoo")
+	if err != nil {
+		t.Fatalf("runtime.AnnotateContext() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		m       forwarder
+		ctx     context.Context
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "disabled",
+			m:    &Marshaler{},
+			ctx:  rctx,
+			want: "",
+		},
+		{
+			name: "method name",
+			m:    &Marshaler{Attachment: true},
+			ctx:  rctx,
+			want: "attachment; filename=ListFoo.csv",
+		},
+		{
+			name: "message name",
+			m:    &Marshaler{Attachment: true, Packaging: PackZip},
+			ctx:  context.Background(),
+			want: "attachment; filename=Api.zip",
+		},
+		{
+			name: "template",
+			m:    &TableMarshaler{Marshaler: Marshaler{Attachment: true, Filename: "{{.Service}} {{.Method}}{{.Ext}}"}},
+			ctx:  rctx,
+			want: "attachment; filename=\"example.FooService ListFoo.txt\"",
+		},
+		{
+			name:    "invalid template",
+			m:       &Marshaler{Attachment: true, Filename: "{{.Method"},
+			ctx:     rctx,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &apipb.Api{}
+			w := httptest.NewRecorder()
+			w.Header().Set("Content-Type", tt.m.ContentType(resp))
+			err := tt.m.ForwardResponseOption(tt.ctx, w, resp)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Marshaler.ForwardResponseOption() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got := w.Header().Get("Content-Disposition"); got != tt.want {
+				t.Errorf("Content-Disposition = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// gateway returns a gateway mux serving resp on '/v1/test' with the
+// marshaler m registered for 'text/csv'.
+func gateway(t *testing.T, m forwarder, resp proto.Message) http.Handler {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption("text/csv", m.(runtime.Marshaler)),
+		runtime.WithForwardResponseOption(m.ForwardResponseOption),
+	)
+	err := mux.HandlePath("GET", "/v1/test", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, outbound := runtime.MarshalerForRequest(mux, r)
+		runtime.ForwardResponseMessage(r.Context(), mux, outbound, w, r, resp, mux.GetForwardResponseOptions()...)
+	})
+	if err != nil {
+		t.Fatalf("runtime.ServeMux.HandlePath() error = %v", err)
+	}
+	return Handler(mux)
+}
+
+// streamGateway returns a gateway mux streaming the responses of recv on
+// '/v1/test' with the marshaler m registered for 'text/csv'.
+func streamGateway(t *testing.T, m forwarder, recv func(ctx context.Context) (proto.Message, error)) http.Handler {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption("text/csv", m.(runtime.Marshaler)),
+		runtime.WithForwardResponseOption(m.ForwardResponseOption),
+	)
+	err := mux.HandlePath("GET", "/v1/test", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, outbound := runtime.MarshalerForRequest(mux, r)
+		ctx := runtime.NewServerMetadataContext(r.Context(), runtime.ServerMetadata{})
+		runtime.ForwardResponseStream(ctx, mux, outbound, w, r, func() (proto.Message, error) {
+			return recv(ctx)
+		}, mux.GetForwardResponseOptions()...)
+	})
+	if err != nil {
+		t.Fatalf("runtime.ServeMux.HandlePath() error = %v", err)
+	}
+	return Handler(mux)
+}
+
+func bound() int {
+	n := 0
+	contexts.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func TestHandler_stream(t *testing.T) {
+	const count = 100
+	n := 0
+	h := streamGateway(t, &Marshaler{}, func(context.Context) (proto.Message, error) {
+		if got := bound(); got != 0 {
+			t.Errorf("bound contexts = %v, want 0", got)
+		}
+		if n == count {
+			return nil, io.EOF
+		}
+		n++
+		return &apipb.Api{Name: "a"}, nil
+	})
+	req := httptest.NewRequest("GET", "/v1/test", nil)
+	req.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if n != count {
+		t.Errorf("received %v, want %v", n, count)
+	}
+	if got := bound(); got != 0 {
+		t.Errorf("bound contexts = %v, want 0", got)
+	}
+}
+
+func TestMarshaler_MarshalStream(t *testing.T) {
+	m := &Marshaler{
+		Tables:    map[string]string{"google.protobuf.Api": "mixins"},
+		Sort:      "name",
+		SortParam: "csv.sort",
+	}
+	n := 0
+	h := streamGateway(t, m, func(context.Context) (proto.Message, error) {
+		if n == 2 {
+			return nil, io.EOF
+		}
+		n++
+		return &apipb.Api{Mixins: []*apipb.Mixin{{Name: "a"}, {Name: "c"}, {Name: "b"}}}, nil
+	})
+	req := httptest.NewRequest("GET", "/v1/test?csv.sort=-name", nil)
+	req.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	got := w.Body.String()
+	// messages are delimited by a newline
+	want := "Name;Root\nc;\nb;\na;\n\n" + "Name;Root\nc;\nb;\na;\n\n"
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("runtime.ForwardResponseStream() generate unexpected results:\n%s", diff)
+	}
+}
@@ -7,6 +7,7 @@ require (
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.13.0
 	github.com/kylelemons/godebug v1.1.0
 	github.com/matoubidou/grpc-gateway-csv v0.0.0-20220308112905-72a65f01b8d4
+	golang.org/x/text v0.4.0
 	google.golang.org/genproto v0.0.0-20221111202108-142d8a6fa32e
 	google.golang.org/grpc v1.50.1
 	google.golang.org/protobuf v1.28.1
@@ -15,5 +16,4 @@ require (
 require (
 	golang.org/x/net v0.2.0 // indirect
 	golang.org/x/sys v0.2.0 // indirect
-	golang.org/x/text v0.4.0 // indirect
 )
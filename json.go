@@ -0,0 +1,220 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// protoJSON renders pm as compact protojson.
+func protoJSON(pm proto.Message) (string, error) {
+	j, err := protojson.Marshal(pm)
+	if err != nil {
+		return "", err
+	}
+	// protojson output is unstable by intention
+	c := &bytes.Buffer{}
+	if err := json.Compact(c, j); err != nil {
+		return "", err
+	}
+	return c.String(), nil
+}
+
+// anyJSON renders the Any message a as JSON or its type URL if the type is unknown.
+func anyJSON(a *anypb.Any) string {
+	s, err := protoJSON(a)
+	if err != nil {
+		return a.GetTypeUrl()
+	}
+	return s
+}
+
+// jsonFormatter renders proto messages as compact protojson.
+func jsonFormatter(v interface{}, printf func(format string, a ...any) string) string {
+	if a, ok := v.(*anypb.Any); ok {
+		return anyJSON(a)
+	}
+	pm, ok := v.(proto.Message)
+	if !ok {
+		return printf("%v", v)
+	}
+	s, err := protoJSON(pm)
+	if err != nil {
+		return printf("%v", v)
+	}
+	return s
+}
+
+// embedJSON reports whether the value v of field f with path p is rendered
+// as JSON to one cell by the JSON column option. Scalars and bytes are never
+// embedded.
+func (m *Marshaler) embedJSON(p string, f reflect.StructField, v reflect.Value) bool {
+	t := followPtrType(v.Type())
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+	case reflect.Struct, reflect.Map:
+	default:
+		return false
+	}
+	return m.column(p, name(f)).JSON
+}
+
+// json renders v as compact JSON. Messages are rendered as protojson,
+// other structs as object of their exported fields named like their
+// columns and maps as object with sorted keys. Structs already visited
+// (cycles) are rendered as null.
+func (m *Marshaler) json(v reflect.Value, visited map[uintptr]*visit) string {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return "null"
+	}
+	if v.Kind() == reflect.Struct && v.CanAddr() {
+		v = v.Addr()
+	}
+	if v.CanInterface() {
+		if pm, ok := v.Interface().(proto.Message); ok {
+			return jsonFormatter(pm, m.Printf)
+		}
+	}
+	v = followPtr(v)
+	switch v.Kind() {
+	case reflect.Struct:
+		if seen(v, visited) {
+			return "null"
+		}
+		s := []string{}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() || strings.HasPrefix(f.Name, "XXX_") {
+				continue
+			}
+			k, _ := json.Marshal(name(f))
+			s = append(s, string(k)+":"+m.json(v.Field(i), visited))
+		}
+		return "{" + strings.Join(s, ",") + "}"
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		s := make([]string, v.Len())
+		for i := range s {
+			s[i] = m.json(v.Index(i), visited)
+		}
+		return "[" + strings.Join(s, ",") + "]"
+	case reflect.Map:
+		s := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			key, _ := json.Marshal(fmt.Sprint(k))
+			s = append(s, string(key)+":"+m.json(v.MapIndex(k), visited))
+		}
+		sort.Strings(s)
+		return "{" + strings.Join(s, ",") + "}"
+	}
+	if !v.CanInterface() {
+		return "null"
+	}
+	j, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "null"
+	}
+	return string(j)
+}
+
+// ParseJSON parses the JSON cell s rendered for embedded messages, slices
+// and maps (see ColumnOptions.JSON) into v, which must be a non-nil pointer.
+// Messages are parsed using protojson, the fields of other structs by their
+// column names.
+func ParseJSON(s string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("csv: ParseJSON requires a non-nil pointer, got %T", v)
+	}
+	if pm, ok := v.(proto.Message); ok {
+		return protojson.Unmarshal([]byte(s), pm)
+	}
+	return parseJSON([]byte(s), rv.Elem())
+}
+
+// parseJSON is the inverse of Marshaler.json, v is settable.
+func parseJSON(data []byte, v reflect.Value) error {
+	if bytes.Equal(data, []byte("null")) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return parseJSON(data, v.Elem())
+	}
+	if pm, ok := v.Addr().Interface().(proto.Message); ok {
+		return protojson.Unmarshal(data, pm)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() || strings.HasPrefix(f.Name, "XXX_") {
+				continue
+			}
+			if raw, ok := fields[name(f)]; ok {
+				if err := parseJSON(raw, v.Field(i)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		elems := []json.RawMessage{}
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return err
+		}
+		s := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+		for i, raw := range elems {
+			if err := parseJSON(raw, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Map:
+		entries := map[string]json.RawMessage{}
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return err
+		}
+		mv := reflect.MakeMapWithSize(v.Type(), len(entries))
+		for k, raw := range entries {
+			// keys are rendered using fmt.Sprint
+			key := reflect.New(v.Type().Key()).Elem()
+			if key.Kind() == reflect.String {
+				key.SetString(k)
+			} else if err := json.Unmarshal([]byte(k), key.Addr().Interface()); err != nil {
+				return err
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := parseJSON(raw, elem); err != nil {
+				return err
+			}
+			mv.SetMapIndex(key, elem)
+		}
+		v.Set(mv)
+		return nil
+	}
+	return json.Unmarshal(data, v.Addr().Interface())
+}
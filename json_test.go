@@ -0,0 +1,195 @@
+package csv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type document struct {
+	Id    string
+	Attrs *structpb.Struct
+	Extra *anypb.Any
+}
+
+type nested struct {
+	Name   string
+	Inner  inner
+	Groups []group
+}
+
+type level1 struct {
+	Inner level2
+}
+
+type level2 struct {
+	Col3 string
+	Deep *level3
+}
+
+type level3 struct {
+	Col3 string
+}
+
+type group struct {
+	Title string
+	Items []inner
+}
+
+func TestMarshaler_MarshalJSON(t *testing.T) {
+
+	attrs, err := structpb.NewStruct(map[string]interface{}{"b": 1, "a": []interface{}{"x", true}})
+	if err != nil {
+		t.Fatalf("structpb.NewStruct() error = %v", err)
+	}
+	extra, err := anypb.New(wrapperspb.String("w"))
+	if err != nil {
+		t.Fatalf("anypb.New() error = %v", err)
+	}
+	n := []nested{{
+		Name:   "n",
+		Inner:  inner{Col3: "c", Col4: 1, Outer: &outer{Col1: "o"}},
+		Groups: []group{{Title: "g", Items: []inner{{Col3: "a"}, {Col3: "b"}}}},
+	}}
+
+	cyclic := []outer{{Col1: "a", Inner: inner{Col3: "b"}}}
+	cyclic[0].Inner.Outer = &cyclic[0] // introduce cycle
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		v    interface{}
+		want string
+	}{
+		{
+			name: "well-known types",
+			m:    &Marshaler{},
+			v:    []document{{Id: "1", Attrs: attrs, Extra: extra}, {Id: "2"}},
+			want: "Id;Attrs;Extra\n" +
+				"1;{\"a\":[\"x\",true],\"b\":1};{\"@type\":\"type.googleapis.com/google.protobuf.StringValue\",\"value\":\"w\"}\n" +
+				"2;;\n",
+		},
+		{
+			name: "column option",
+			m:    &Marshaler{Columns: map[string]ColumnOptions{"Groups": {JSON: true}}, Null: "null"},
+			v:    []nested{{Name: "n", Groups: []group{{Title: "g", Items: []inner{{Col3: "a"}}}}}},
+			want: "Name;Col3;Col4;Col5;Groups\n" +
+				"n;;0;0;[{\"Title\":\"g\",\"Items\":[{\"Col3\":\"a\",\"Col4\":0,\"Col5\":0,\"Outer\":null}]}]\n",
+		},
+		{
+			name: "depth with repeated in repeated",
+			m:    &Marshaler{MaxDepth: 2, Recursion: RecursionJSON},
+			v:    n,
+			want: "Name;Col3;Col4;Col5;Outer;Groups\n" +
+				"n;c;1;0;{\"Col1\":\"o\",\"Col2\":\"\",\"slice\":[],\"map1\":{},\"map2\":{},\"Inner\":{\"Col3\":\"\",\"Col4\":0,\"Col5\":0,\"Outer\":null},\"InnerSlice\":[]};" +
+				"g|[{\"Col3\":\"a\",\"Col4\":0,\"Col5\":0,\"Outer\":null},{\"Col3\":\"b\",\"Col4\":0,\"Col5\":0,\"Outer\":null}]\n",
+		},
+		{
+			name: "documented depth",
+			m:    &Marshaler{MaxDepth: 2, Recursion: RecursionJSON},
+			v:    []level1{{Inner: level2{Col3: "a", Deep: &level3{Col3: "b"}}}},
+			want: "Col3;Deep\na;{\"Col3\":\"b\"}\n",
+		},
+		{
+			name: "cycle",
+			m:    &Marshaler{Columns: map[string]ColumnOptions{"Inner": {JSON: true}}},
+			v:    cyclic,
+			want: "Col1;Col2;slice;map1;map2;Inner;InnerSlice\n" +
+				"a;;;;;{\"Col3\":\"b\",\"Col4\":0,\"Col5\":0,\"Outer\":null};\n",
+		},
+		{
+			name: "nil message",
+			m:    &Marshaler{MaxDepth: 2, Recursion: RecursionJSON, Null: "-"},
+			v:    []nested{{Name: "n"}},
+			want: "Name;Col3;Col4;Col5;Outer;Groups\nn;;0;0;-;\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(tt.v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		s       string
+		v       interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "struct by column names",
+			s:    `[{"Title":"g","Items":[{"Col3":"a","Col4":1,"Col5":0,"Outer":null}]}]`,
+			v:    &[]group{},
+			want: &[]group{{Title: "g", Items: []inner{{Col3: "a", Col4: 1}}}},
+		},
+		{
+			name: "tagged columns and maps",
+			s:    `{"Col1":"a","Col2":"","slice":["x"],"map1":{"1":2},"map2":null,"Inner":{"Col3":"b","Col4":0,"Col5":0,"Outer":null},"InnerSlice":[]}`,
+			v:    &outer{},
+			want: &outer{Col1: "a", S: []string{"x"}, M1: map[int]int{1: 2}, Inner: inner{Col3: "b"}, InnerSlice: []inner{}},
+		},
+		{
+			name:    "no pointer",
+			s:       `{}`,
+			v:       outer{},
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			s:       `{"Col1":1}`,
+			v:       &outer{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ParseJSON(tt.s, tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if diff := pretty.Compare(tt.v, tt.want); diff != "" {
+				t.Errorf("ParseJSON() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseJSON_message(t *testing.T) {
+	m := &Marshaler{NoHeader: true}
+	attrs, err := structpb.NewStruct(map[string]interface{}{"b": 1, "a": []interface{}{"x", true}})
+	if err != nil {
+		t.Fatalf("structpb.NewStruct() error = %v", err)
+	}
+	g, err := m.Marshal([]document{{Id: "1", Attrs: attrs}})
+	if err != nil {
+		t.Fatalf("CSVMarshaler.Marshal() error = %v", err)
+	}
+	got := &structpb.Struct{}
+	if err := ParseJSON(strings.Split(strings.TrimSuffix(string(g), "\n"), ";")[1], got); err != nil {
+		t.Fatalf("ParseJSON() error = %v", err)
+	}
+	if !proto.Equal(got, attrs) {
+		t.Errorf("ParseJSON() = %v, want %v", got, attrs)
+	}
+}
@@ -0,0 +1,89 @@
+package csv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTruncateMarker is the row appended to truncated blocks if
+// Marshaler.TruncateMarker is empty.
+const DefaultTruncateMarker = "# truncated"
+
+// LimitError is returned if a response exceeds MaxRows or MaxBytes and
+// Truncate is not set.
+type LimitError struct {
+	// Limit names the exceeded option, 'MaxRows' or 'MaxBytes'.
+	Limit string
+	// Max is the value of the exceeded option.
+	Max int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s (%d) exceeded", e.Limit, e.Max)
+}
+
+// usage accounts the rows and bytes rendered by one marshaling against
+// MaxRows and MaxBytes.
+type usage struct {
+	rows  int
+	bytes int
+	// exceeded is set once a limit is reached, all following rows are dropped
+	exceeded bool
+}
+
+// admit accounts the cells of one row (or the header) of a block. It
+// reports false if the row exceeds MaxRows or MaxBytes. Then the block is
+// marked as truncated with Truncate, otherwise m.err is set to a LimitError.
+func (m *Marshaler) admit(b *block, cells []string, header bool) bool {
+	if m.used.exceeded {
+		return false
+	}
+	var err *LimitError
+	n := len(strings.Join(cells, m.FieldDelim)) + len(m.RowDelim)
+	switch {
+	case !header && m.MaxRows > 0 && m.used.rows >= m.MaxRows:
+		err = &LimitError{"MaxRows", m.MaxRows}
+	case m.MaxBytes > 0 && m.used.bytes+n > m.MaxBytes:
+		err = &LimitError{"MaxBytes", m.MaxBytes}
+	}
+	if err != nil {
+		m.used.exceeded = true
+		if m.Truncate {
+			b.truncated = true
+		} else if m.err == nil {
+			m.err = err
+		}
+		return false
+	}
+	if !header {
+		m.used.rows++
+	}
+	m.used.bytes += n
+	return true
+}
+
+// limit drops the rows of the block b exceeding MaxRows or MaxBytes. It
+// reports false if the block is omitted, see dropHeader.
+func (m *Marshaler) limit(b *block) bool {
+	if !m.NoHeader && !m.admit(b, b.header, true) {
+		return dropHeader(b)
+	}
+	for i, row := range b.rows {
+		if !m.admit(b, row, false) {
+			b.rows = b.rows[:i]
+			break
+		}
+	}
+	return true
+}
+
+// dropHeader handles the block b whose header exceeds MaxBytes. With
+// Truncate b is kept without header and rows to render the truncate marker
+// only, otherwise (or if a previous block was truncated) it is omitted.
+func dropHeader(b *block) bool {
+	if !b.truncated {
+		return false
+	}
+	b.header, b.rows = nil, nil
+	return true
+}
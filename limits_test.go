@@ -0,0 +1,105 @@
+package csv
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestMarshaler_MarshalLimits(t *testing.T) {
+
+	v := &listResponse{
+		Items:    []inner{{Col3: "a"}, {Col3: "b"}, {Col3: "c"}},
+		Warnings: []inner{{Col3: "w"}},
+	}
+
+	tests := []struct {
+		name    string
+		m       *Marshaler
+		v       interface{}
+		want    string
+		wantErr *LimitError
+	}{
+		{
+			name: "within limits",
+			m:    &Marshaler{MaxRows: 4, MaxBytes: 100},
+			v:    v,
+			want: "Col3;Col4;Col5\na;0;0\nb;0;0\nc;0;0\n---\nCol3;Col4;Col5\nw;0;0\n",
+		},
+		{
+			name:    "max rows",
+			m:       &Marshaler{MaxRows: 2},
+			v:       v,
+			wantErr: &LimitError{"MaxRows", 2},
+		},
+		{
+			name:    "max bytes",
+			m:       &Marshaler{MaxBytes: 20},
+			v:       v,
+			wantErr: &LimitError{"MaxBytes", 20},
+		},
+		{
+			name: "truncate rows",
+			m:    &Marshaler{MaxRows: 2, Truncate: true},
+			v:    v,
+			want: "Col3;Col4;Col5\na;0;0\nb;0;0\n# truncated\n",
+		},
+		{
+			name: "truncate bytes with marker",
+			m:    &Marshaler{MaxBytes: 30, Truncate: true, TruncateMarker: "..."},
+			v:    v,
+			want: "Col3;Col4;Col5\na;0;0\nb;0;0\n...\n",
+		},
+		{
+			name: "header exceeds max bytes",
+			m:    &Marshaler{MaxBytes: 10, Truncate: true},
+			v:    v,
+			want: "# truncated\n",
+		},
+		{
+			name: "header exceeds max bytes with section",
+			m:    &Marshaler{MaxBytes: 10, Truncate: true, SectionColumn: true, BlockTitle: true},
+			v:    v,
+			want: "# Items\n# truncated\n",
+		},
+		{
+			name:    "header exceeds max bytes w/o truncate",
+			m:       &Marshaler{MaxBytes: 10},
+			v:       v,
+			wantErr: &LimitError{"MaxBytes", 10},
+		},
+		{
+			name: "header exceeds max bytes single",
+			m:    &Marshaler{MaxBytes: 10, Truncate: true, Single: SingleRow},
+			v:    inner{Col3: "a"},
+			want: "# truncated\n",
+		},
+		{
+			name: "truncate single key value",
+			m:    &Marshaler{MaxRows: 1, Truncate: true, Single: SingleKeyValue},
+			v:    inner{Col3: "a"},
+			want: "field;value\nCol3;a\n# truncated\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(tt.v)
+			if tt.wantErr != nil {
+				var le *LimitError
+				if !errors.As(err, &le) || *le != *tt.wantErr {
+					t.Errorf("CSVMarshaler.Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package csv
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// forRequest returns a copy of m with the options specific to the request
+// stored in ctx applied.
+func (m *Marshaler) forRequest(ctx context.Context) *Marshaler {
+	c := *m
+	tag := language.Und
+	if len(c.Languages) > 0 {
+		tag = c.Languages[0]
+	}
+	if r, ok := request(ctx); ok {
+		if t, ok := c.language(r.Header.Get("Accept-Language")); ok {
+			tag = t
+			p := c.printer(tag)
+			c.Printf = func(format string, a ...any) string { return p.Sprintf(format, a...) }
+			if c.AutoDelim {
+				c.FieldDelim = ","
+				if strings.Contains(p.Sprint(1.5), ",") {
+					c.FieldDelim = ";"
+				}
+			}
+		}
+		if loc, ok := c.location(r); ok {
+			c.Time.Location = loc
+		}
+		if s := r.URL.Query().Get(c.SortParam); c.SortParam != "" && s != "" {
+			c.Sort = s
+		}
+	}
+	if c.Catalog != nil {
+		c.headers = c.printer(tag)
+	}
+	return &c
+}
+
+// location returns the time zone selected by the request r using the
+// TimezoneParam or TimezoneHeader option. Unknown zones are ignored.
+func (m *Marshaler) location(r *http.Request) (*time.Location, bool) {
+	tz := ""
+	if m.TimezoneHeader != "" {
+		tz = r.Header.Get(m.TimezoneHeader)
+	}
+	if m.TimezoneParam != "" && r.URL.Query().Get(m.TimezoneParam) != "" {
+		tz = r.URL.Query().Get(m.TimezoneParam)
+	}
+	if tz == "" {
+		return nil, false
+	}
+	loc, err := time.LoadLocation(tz)
+	return loc, err == nil
+}
+
+// printer returns the printer for tag using the Catalog if set.
+func (m *Marshaler) printer(tag language.Tag) *message.Printer {
+	if m.Catalog != nil {
+		return message.NewPrinter(tag, message.Catalog(m.Catalog))
+	}
+	return message.NewPrinter(tag)
+}
+
+// header returns the header of the field f with path p of the struct type
+// t translated by the Catalog.
+func (m *Marshaler) header(t reflect.Type, p string, f reflect.StructField) string {
+	n := name(f)
+	if m.headers == nil {
+		return n
+	}
+	keys := []string{p}
+	if fn, pn := protoFullName(t), protoName(f); fn != "" && pn != "" {
+		keys = append(keys, string(fn)+"."+pn)
+	}
+	for _, k := range keys {
+		if s := m.headers.Sprintf(k); s != k {
+			return s
+		}
+	}
+	return n
+}
+
+// language matches the Accept-Language header h against the supported
+// Languages.
+func (m *Marshaler) language(h string) (language.Tag, bool) {
+	if len(m.Languages) == 0 || h == "" {
+		return language.Und, false
+	}
+	tags, _, err := language.ParseAcceptLanguage(h)
+	if err != nil || len(tags) == 0 {
+		return language.Und, false
+	}
+	tag, _, _ := language.NewMatcher(m.Languages).Match(tags...)
+	return tag, true
+}
@@ -0,0 +1,115 @@
+package csv
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message/catalog"
+	"google.golang.org/protobuf/types/known/apipb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestMarshaler_MarshalAcceptLanguage(t *testing.T) {
+
+	m := &Marshaler{
+		Single:    SingleKeyValue,
+		Languages: []language.Tag{language.English, language.German},
+		AutoDelim: true,
+	}
+	h := gateway(t, m, wrapperspb.Double(1234.5))
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{
+			name: "no accept-language",
+			want: "field;value\nValue;1234.5\n",
+		},
+		{
+			name:           "english",
+			acceptLanguage: "en-US,en;q=0.9",
+			want:           "field,value\nValue,1,234.5\n",
+		},
+		{
+			name:           "german",
+			acceptLanguage: "fr;q=0.9,de-DE;q=0.8",
+			want:           "field;value\nValue;1.234,5\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/test", nil)
+			req.Header.Set("Accept", "text/csv")
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			got := w.Body.String()
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshaler_MarshalCatalog(t *testing.T) {
+
+	c := catalog.NewBuilder(catalog.Fallback(language.English))
+	c.SetString(language.German, "Inner.Col3", "Spalte 3")
+	c.SetString(language.German, "google.protobuf.Method.name", "Methode")
+	c.SetString(language.French, "google.protobuf.Method.name", "Méthode")
+
+	api := &apipb.Api{Methods: []*apipb.Method{{Name: "Get"}}}
+	m := &Marshaler{
+		Tables:    map[string]string{"google.protobuf.Api": "methods"},
+		Languages: []language.Tag{language.German, language.French},
+		Catalog:   c,
+	}
+	h := gateway(t, m, api)
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{
+			name: "default language",
+			want: "Methode;RequestTypeUrl;RequestStreaming;ResponseTypeUrl;ResponseStreaming;Options;Syntax\nGet;;false;;false;;SYNTAX_PROTO2\n",
+		},
+		{
+			name:           "french",
+			acceptLanguage: "fr",
+			want:           "Méthode;RequestTypeUrl;RequestStreaming;ResponseTypeUrl;ResponseStreaming;Options;Syntax\nGet;;false;;false;;SYNTAX_PROTO2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/test", nil)
+			req.Header.Set("Accept", "text/csv")
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			got := w.Body.String()
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+
+	// field path, w/o request
+	g, err := (&Marshaler{Languages: []language.Tag{language.German}, Catalog: c}).Marshal([]outer{{}})
+	if err != nil {
+		t.Fatalf("CSVMarshaler.Marshal() error = %v", err)
+	}
+	want := "Col1;Col2;slice;map1;map2;Spalte 3;Col4;Col5;InnerSlice\n;;;;;;0;0;\n"
+	if diff := pretty.Compare(string(g), want); diff != "" {
+		t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+	}
+}
@@ -0,0 +1,92 @@
+package csv
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+)
+
+// Packaging specifies how the blocks of multiple top-level slices are packaged.
+type Packaging int
+
+const (
+	// PackInline renders all blocks to one body delimited by '---\n'.
+	PackInline Packaging = iota
+	// PackZip renders a ZIP archive containing one CSV file per block.
+	PackZip
+	// PackMultipart renders a multipart/mixed body containing one text/csv part per block.
+	PackMultipart
+)
+
+// boundary returns Boundary or the random boundary generated once per
+// Marshaler, which ContentType and Marshal of concurrent requests share.
+// A fixed boundary is more likely contained in the cells.
+func (m *Marshaler) boundary() string {
+	if m.Boundary != "" {
+		return m.Boundary
+	}
+	m.generatedBoundary.CompareAndSwap(nil, multipart.NewWriter(nil).Boundary())
+	return m.generatedBoundary.Load().(string)
+}
+
+// filename returns the name of the file a block is packaged to.
+func filename(b block) string {
+	return b.title() + ".csv"
+}
+
+func (m *Marshaler) zip(blocks []block) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, b := range blocks {
+		f, err := w.Create(filename(b))
+		if err != nil {
+			return nil, err
+		}
+		s, err := m.encode(m.render(b))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(s); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *Marshaler) multipart(blocks []block) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	boundary := m.boundary()
+	if err := w.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", m.withCharset("text/csv"))
+		h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename(b)))
+		p, err := w.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+		s, err := m.encode(m.render(b))
+		if err != nil {
+			return nil, err
+		}
+		// the boundary is already sent with the Content-Type
+		if bytes.Contains(s, []byte("--"+boundary)) {
+			return nil, fmt.Errorf("block %s contains the multipart boundary", b.title())
+		}
+		if _, err := p.Write(s); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,129 @@
+package csv
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"sync"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+var packV = struct {
+	A []inner `csv:"first"`
+	B []inner
+	C []inner
+}{
+	A: []inner{{Col3: "a", Col4: 1}},
+	B: []inner{{Col3: "b", Col4: 2}},
+}
+
+func TestMarshaler_MarshalZip(t *testing.T) {
+	m := &Marshaler{Packaging: PackZip}
+	g, err := m.Marshal(packV)
+	if err != nil {
+		t.Fatalf("Marshaler.Marshal() error = %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(g), int64(len(g)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	got := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("zip.File.Open() error = %v", err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		got[f.Name] = string(b)
+	}
+	want := map[string]string{
+		"first.csv": "Col3;Col4;Col5\na;1;0\n",
+		"B.csv":     "Col3;Col4;Col5\nb;2;0\n",
+	}
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("Marshaler.Marshal() generate unexpected results:\n%s", diff)
+	}
+	if got := m.ContentType(nil); got != "application/zip" {
+		t.Errorf("Marshaler.ContentType() = %v, want %v", got, "application/zip")
+	}
+}
+
+func TestMarshaler_MarshalMultipart(t *testing.T) {
+	m := &Marshaler{Packaging: PackMultipart}
+	g, err := m.Marshal(packV)
+	if err != nil {
+		t.Fatalf("Marshaler.Marshal() error = %v", err)
+	}
+	mt, params, err := mime.ParseMediaType(m.ContentType(nil))
+	if err != nil || mt != "multipart/mixed" {
+		t.Fatalf("Marshaler.ContentType() = %v, %v", mt, err)
+	}
+	r := multipart.NewReader(bytes.NewReader(g), params["boundary"])
+	got := map[string]string{}
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("multipart.Reader.NextPart() error = %v", err)
+		}
+		if ct := p.Header.Get("Content-Type"); ct != "text/csv" {
+			t.Errorf("part Content-Type = %v, want text/csv", ct)
+		}
+		b, _ := io.ReadAll(p)
+		got[p.FileName()] = string(b)
+	}
+	want := map[string]string{
+		"first.csv": "Col3;Col4;Col5\na;1;0\n",
+		"B.csv":     "Col3;Col4;Col5\nb;2;0\n",
+	}
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("Marshaler.Marshal() generate unexpected results:\n%s", diff)
+	}
+}
+
+func TestMarshaler_ContentTypeBoundary(t *testing.T) {
+	m1 := &Marshaler{Packaging: PackMultipart}
+	m2 := &Marshaler{Packaging: PackMultipart}
+	if m1.ContentType(nil) != m1.ContentType(nil) {
+		t.Errorf("Marshaler.ContentType() boundary changed between calls")
+	}
+	if m1.ContentType(nil) == m2.ContentType(nil) {
+		t.Errorf("Marshaler.ContentType() same boundary for different marshalers: %v", m1.ContentType(nil))
+	}
+}
+
+func TestMarshaler_ContentTypeBoundaryConcurrent(t *testing.T) {
+	m := &Marshaler{Packaging: PackMultipart}
+	got := make([]string, 10)
+	wg := sync.WaitGroup{}
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = m.ContentType(nil)
+		}(i)
+	}
+	wg.Wait()
+	for _, ct := range got {
+		if ct != got[0] {
+			t.Errorf("Marshaler.ContentType() = %v, want %v", ct, got[0])
+		}
+	}
+}
+
+func TestMarshaler_MarshalMultipartBoundaryInCell(t *testing.T) {
+	m := &Marshaler{Packaging: PackMultipart, Boundary: "b"}
+	if _, err := m.Marshal([]inner{{Col3: "--b"}}); err == nil {
+		t.Errorf("Marshaler.Marshal() error = nil, want boundary error")
+	}
+	if _, err := m.Marshal([]inner{{Col3: "-b"}}); err != nil {
+		t.Errorf("Marshaler.Marshal() error = %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package csv
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// table returns the name of the slice field selected by the Tables option
+// for the response type of i.
+func (m *Marshaler) table(i interface{}) (string, bool) {
+	if len(m.Tables) == 0 {
+		return "", false
+	}
+	if pm, ok := i.(proto.Message); ok {
+		if f, ok := m.Tables[string(pm.ProtoReflect().Descriptor().FullName())]; ok {
+			return f, true
+		}
+	}
+	f, ok := m.Tables[followPtr(reflect.ValueOf(i)).Type().String()]
+	return f, ok
+}
+
+// matches reports whether the field f is referenced by n, which might
+// be the Go field name, the csv tag or the proto field name.
+func matches(f reflect.StructField, n string) bool {
+	return f.Name == n || name(f) == n || protoName(f) == n
+}
+
+// protoName returns the proto field name of f taken from the protobuf
+// struct tag of generated messages.
+func protoName(f reflect.StructField) string {
+	for _, s := range strings.Split(f.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(s, "name=") {
+			return strings.TrimPrefix(s, "name=")
+		}
+	}
+	return ""
+}
+
+// Single specifies how responses without slice fields (single messages) are rendered.
+type Single int
+
+const (
+	// SingleNone ignores single messages, the rendered body is empty.
+	SingleNone Single = iota
+	// SingleRow renders a single message as table with one row.
+	SingleRow
+	// SingleKeyValue renders a single message vertically as 'field;value' rows.
+	SingleKeyValue
+)
+
+// marshalSingle flattens the single message v to a block according to
+// the Single option.
+func (m *Marshaler) marshalSingle(v reflect.Value) *block {
+	switch m.Single {
+	case SingleRow:
+		b := &block{
+			header: m.marshal(v, true, map[uintptr]*visit{}, ""),
+			rows:   [][]string{m.marshal(v, false, map[uintptr]*visit{}, "")},
+		}
+		if !m.limit(b) {
+			return nil
+		}
+		return b
+	case SingleKeyValue:
+		b := &block{header: []string{"field", "value"}}
+		header := m.marshal(v, true, map[uintptr]*visit{}, "")
+		row := m.marshal(v, false, map[uintptr]*visit{}, "")
+		for i := range header {
+			if i < len(row) {
+				b.rows = append(b.rows, []string{header[i], row[i]})
+			}
+		}
+		if !m.limit(b) {
+			return nil
+		}
+		return b
+	}
+	return nil
+}
+
+type scalar struct {
+	name  string
+	value string
+	// header is the HTTP header name derived from the proto field name
+	header string
+}
+
+// scalars returns all non-zero scalar fields of the response i. Scalars
+// of responses which are no structs are not supported.
+func (m *Marshaler) scalars(i interface{}) []scalar {
+	v := followPtr(reflect.ValueOf(i))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	res := []scalar{}
+	for i := 0; i < v.NumField(); i++ {
+		val := v.Field(i)
+		typ := v.Type().Field(i)
+		if !typ.IsExported() || strings.HasPrefix(typ.Name, "XXX_") {
+			continue
+		}
+		if val.Kind() == reflect.Ptr && !val.IsNil() {
+			val = val.Elem()
+		}
+		switch val.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Struct, reflect.Ptr, reflect.Interface:
+			continue
+		}
+		if val.IsZero() {
+			continue
+		}
+		h := protoName(typ)
+		if h == "" {
+			h = name(typ)
+		}
+		h = http.CanonicalHeaderKey(strings.ReplaceAll(h, "_", "-"))
+		res = append(res, scalar{name(typ), m.Printf("%v", val), h})
+	}
+	return res
+}
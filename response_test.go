@@ -0,0 +1,179 @@
+package csv
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"google.golang.org/protobuf/types/known/apipb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type listResponse struct {
+	Items         []inner `protobuf:"bytes,1,rep,name=items,proto3"`
+	Warnings      []inner `protobuf:"bytes,2,rep,name=warnings,proto3"`
+	NextPageToken string  `protobuf:"bytes,3,opt,name=next_page_token,json=nextPageToken,proto3"`
+	TotalSize     int32   `csv:"total"`
+}
+
+func TestMarshaler_MarshalTables(t *testing.T) {
+
+	v := &listResponse{
+		Items:         []inner{{Col3: "a", Col4: 1}},
+		Warnings:      []inner{{Col3: "w"}},
+		NextPageToken: "abc",
+	}
+	api := &apipb.Api{
+		Name:    "api",
+		Version: "v1",
+		Methods: []*apipb.Method{{Name: "Get"}},
+		Mixins:  []*apipb.Mixin{{Name: "mixin"}},
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		v    interface{}
+		want string
+	}{
+		{
+			name: "all slices",
+			m:    &Marshaler{},
+			v:    v,
+			want: "Col3;Col4;Col5\na;1;0\n---\nCol3;Col4;Col5\nw;0;0\n",
+		},
+		{
+			name: "select by go type and proto field name",
+			m:    &Marshaler{Tables: map[string]string{"csv.listResponse": "items"}},
+			v:    v,
+			want: "Col3;Col4;Col5\na;1;0\n",
+		},
+		{
+			name: "select by go field name with scalar comments",
+			m:    &Marshaler{Tables: map[string]string{"csv.listResponse": "Warnings"}, ScalarComments: true},
+			v:    v,
+			want: "Col3;Col4;Col5\nw;0;0\n# NextPageToken: abc\n",
+		},
+		{
+			name: "select by proto full name",
+			m:    &Marshaler{Tables: map[string]string{"google.protobuf.Api": "mixins"}},
+			v:    api,
+			want: "Name;Root\nmixin;\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(tt.v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshaler_ForwardResponseOptionScalarHeaders(t *testing.T) {
+	api := &apipb.Api{Name: "api", Version: "v1"}
+	m := &Marshaler{ScalarHeaderPrefix: "X-Csv-"}
+
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "application/json")
+	if err := m.ForwardResponseOption(context.Background(), w, api); err != nil {
+		t.Fatalf("Marshaler.ForwardResponseOption() error = %v", err)
+	}
+	if got := w.Header().Get("X-Csv-Name"); got != "" {
+		t.Errorf("header set for other marshaler: %v", got)
+	}
+
+	w = httptest.NewRecorder()
+	w.Header().Set("Content-Type", m.ContentType(api))
+	if err := m.ForwardResponseOption(context.Background(), w, api); err != nil {
+		t.Fatalf("Marshaler.ForwardResponseOption() error = %v", err)
+	}
+	got := map[string]string{
+		"X-Csv-Name":    w.Header().Get("X-Csv-Name"),
+		"X-Csv-Version": w.Header().Get("X-Csv-Version"),
+		"X-Csv-Syntax":  w.Header().Get("X-Csv-Syntax"),
+	}
+	want := map[string]string{
+		"X-Csv-Name":    "api",
+		"X-Csv-Version": "v1",
+		"X-Csv-Syntax":  "",
+	}
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("Marshaler.ForwardResponseOption() unexpected headers:\n%s", diff)
+	}
+}
+
+func TestMarshaler_MarshalSingle(t *testing.T) {
+
+	v := &inner{Col3: "a", Col4: 1, Col5: 1.5}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		v    interface{}
+		want string
+	}{
+		{
+			name: "ignored",
+			m:    &Marshaler{},
+			v:    v,
+			want: "",
+		},
+		{
+			name: "one row",
+			m:    &Marshaler{Single: SingleRow},
+			v:    v,
+			want: "Col3;Col4;Col5\na;1;1.5\n",
+		},
+		{
+			name: "key value",
+			m:    &Marshaler{Single: SingleKeyValue},
+			v:    *v,
+			want: "field;value\nCol3;a\nCol4;1\nCol5;1.5\n",
+		},
+		{
+			name: "struct with empty slices",
+			m:    &Marshaler{Single: SingleRow},
+			v:    &listResponse{NextPageToken: "abc"},
+			want: "",
+		},
+		{
+			name: "proto message",
+			m:    &Marshaler{Single: SingleRow},
+			v:    wrapperspb.String("a"),
+			want: "Value\na\n",
+		},
+		{
+			name: "repeated scalars",
+			m:    &Marshaler{Single: SingleKeyValue},
+			v:    &struct{ Tags []string }{Tags: []string{"a", "b"}},
+			want: "field;value\nTags;a|b\n",
+		},
+		{
+			name: "bytes",
+			m:    &Marshaler{Single: SingleRow},
+			v:    wrapperspb.Bytes([]byte("a")),
+			want: "Value\nYQ==\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(tt.v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
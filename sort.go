@@ -0,0 +1,250 @@
+package csv
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/genproto/googleapis/type/money"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// sortKey is a field of the top-level slice elements rows are sorted by.
+type sortKey struct {
+	// index is the field index sequence (see reflect.Type.FieldByIndex)
+	index []int
+	desc  bool
+}
+
+// sortKeys resolves the Sort option for the element type t. Keys which are
+// no fields of t or not sortable are ignored.
+func (m *Marshaler) sortKeys(t reflect.Type) []sortKey {
+	keys := []sortKey{}
+	for _, s := range strings.Split(m.Sort, ",") {
+		s = strings.TrimSpace(s)
+		k := sortKey{desc: strings.HasPrefix(s, "-")}
+		s = strings.TrimLeft(s, "+-")
+		if s == "" {
+			continue
+		}
+		var ok bool
+		if k.index, ok = fieldIndex(t, strings.Split(s, ".")); !ok && !strings.Contains(s, ".") {
+			k.index, ok = searchField(t, s, map[reflect.Type]bool{})
+		}
+		if ok && sortable(fieldType(t, k.index)) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// fieldIndex returns the index sequence of the field path p of the struct t.
+func fieldIndex(t reflect.Type, p []string) ([]int, bool) {
+	index := []int{}
+	for _, n := range p {
+		t = followPtrType(t)
+		if t.Kind() != reflect.Struct {
+			return nil, false
+		}
+		found := false
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() && matches(f, n) {
+				index = append(index, i)
+				t = f.Type
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return index, true
+}
+
+// searchField returns the index sequence of the first field named n of the
+// struct t or its nested structs, i.e. the column with header n.
+func searchField(t reflect.Type, n string, seen map[reflect.Type]bool) ([]int, bool) {
+	t = followPtrType(t)
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil, false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || strings.HasPrefix(f.Name, "XXX_") {
+			continue
+		}
+		if matches(f, n) {
+			return []int{i}, true
+		}
+		if index, ok := searchField(f.Type, n, seen); ok {
+			return append([]int{i}, index...), true
+		}
+	}
+	return nil, false
+}
+
+// fieldType returns the type of the field with the index sequence index of
+// the struct t.
+func fieldType(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		t = followPtrType(t).Field(i).Type
+	}
+	return t
+}
+
+// sortable reports whether values of type t are ordered: scalars, times,
+// timestamps, wrappers of scalars, google.type.Money and Decimal.
+func sortable(t reflect.Type) bool {
+	t = followPtrType(t)
+	if t == timeType {
+		return true
+	}
+	switch n := string(protoFullName(t)); {
+	case n == "google.protobuf.Timestamp", n == "google.type.Money", n == "google.type.Decimal":
+		return true
+	case strings.HasPrefix(n, "google.protobuf.") && strings.HasSuffix(n, "Value"):
+		f, ok := t.FieldByName("Value")
+		return ok && sortable(f.Type)
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool, reflect.String:
+		return true
+	}
+	return false
+}
+
+// value returns the field of k of the struct v. The returned value is invalid
+// if a message on the way is nil.
+func (k sortKey) value(v reflect.Value) reflect.Value {
+	for _, i := range k.index {
+		v = followPtr(v)
+		if !v.IsValid() {
+			return v
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// order returns the indexes of the elements of the top-level slice v sorted
+// according to the Sort option.
+func (m *Marshaler) order(v reflect.Value) []int {
+	index := make([]int, v.Len())
+	for i := range index {
+		index[i] = i
+	}
+	if m.Sort == "" {
+		return index
+	}
+	keys := m.sortKeys(v.Type().Elem())
+	sort.SliceStable(index, func(i, j int) bool {
+		for _, k := range keys {
+			c := compare(k.value(v.Index(index[i])), k.value(v.Index(index[j])))
+			if k.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return index
+}
+
+// sortValue returns the comparable value of v: timestamps as time.Time,
+// decimals as *big.Rat, wrapper messages as their value and nil pointers or
+// invalid decimals as invalid value.
+func sortValue(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return reflect.Value{}
+	}
+	if v.Kind() == reflect.Struct && v.CanAddr() {
+		// message value field
+		v = v.Addr()
+	}
+	switch x := v.Interface().(type) {
+	case *timestamppb.Timestamp:
+		return reflect.ValueOf(x.AsTime())
+	case *decimal.Decimal:
+		r, ok := new(big.Rat).SetString(x.GetValue())
+		if !ok {
+			return reflect.Value{}
+		}
+		return reflect.ValueOf(r)
+	case *money.Money:
+		return v
+	}
+	if n := protoFullName(v.Type()); strings.HasPrefix(string(n), "google.protobuf.") && strings.HasSuffix(string(n), "Value") {
+		if w := v.Elem().FieldByName("Value"); w.IsValid() {
+			return w
+		}
+	}
+	return followPtr(v)
+}
+
+// compare compares the field values a and b of the same sortable type by
+// their kind. Nil values sort first, money by currency code and amount.
+func compare(a, b reflect.Value) int {
+	if a.IsValid() && a.CanInterface() {
+		a = sortValue(a)
+	}
+	if b.IsValid() && b.CanInterface() {
+		b = sortValue(b)
+	}
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return 0
+	case !a.IsValid():
+		return -1
+	case !b.IsValid():
+		return 1
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp(a.Int() < b.Int(), a.Int() > b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp(a.Uint() < b.Uint(), a.Uint() > b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp(a.Float() < b.Float(), a.Float() > b.Float())
+	case reflect.Bool:
+		return cmp(!a.Bool() && b.Bool(), a.Bool() && !b.Bool())
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	}
+	if a.Type() == timeType {
+		ta, tb := a.Interface().(time.Time), b.Interface().(time.Time)
+		return cmp(ta.Before(tb), ta.After(tb))
+	}
+	switch a := a.Interface().(type) {
+	case *big.Rat:
+		return a.Cmp(b.Interface().(*big.Rat))
+	case *money.Money:
+		b := b.Interface().(*money.Money)
+		if c := strings.Compare(a.GetCurrencyCode(), b.GetCurrencyCode()); c != 0 {
+			return c
+		}
+		if c := cmp(a.GetUnits() < b.GetUnits(), a.GetUnits() > b.GetUnits()); c != 0 {
+			return c
+		}
+		return cmp(a.GetNanos() < b.GetNanos(), a.GetNanos() > b.GetNanos())
+	}
+	return 0
+}
+
+func cmp(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,164 @@
+package csv
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+	"google.golang.org/genproto/googleapis/type/date"
+	"google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/genproto/googleapis/type/money"
+	"google.golang.org/protobuf/types/known/apipb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMarshaler_MarshalSort(t *testing.T) {
+
+	rows := []*inner{
+		{Col3: "b", Col4: 2, Outer: &outer{Col1: "y"}},
+		{Col3: "a", Col4: 10},
+		{Col3: "c", Col4: 2, Outer: &outer{Col1: "x"}},
+	}
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	events := []event{
+		{Name: "later", Created: timestamppb.New(now.Add(time.Hour))},
+		{Name: "unset"},
+		{Name: "earlier", Created: timestamppb.New(now)},
+	}
+	type item struct {
+		Name   string
+		Price  *money.Money
+		Amount *decimal.Decimal
+		Day    *date.Date
+	}
+	items := []item{
+		{Name: "a", Price: &money.Money{CurrencyCode: "USD", Units: 1}, Amount: &decimal.Decimal{Value: "10"}, Day: &date.Date{Year: 2026}},
+		{Name: "b", Price: &money.Money{CurrencyCode: "EUR", Units: 10}, Amount: &decimal.Decimal{Value: "-1e1"}, Day: &date.Date{Year: 2025}},
+		{Name: "c", Price: &money.Money{CurrencyCode: "EUR", Units: 2, Nanos: 500000000}, Amount: &decimal.Decimal{Value: "9.5"}},
+	}
+
+	tests := []struct {
+		name string
+		m    *Marshaler
+		v    interface{}
+		want []string
+	}{
+		{
+			name: "unsorted",
+			m:    &Marshaler{},
+			v:    rows,
+			want: []string{"b", "a", "c"},
+		},
+		{
+			name: "numeric, stable",
+			m:    &Marshaler{Sort: "Col4"},
+			v:    rows,
+			want: []string{"b", "c", "a"},
+		},
+		{
+			name: "descending with second key",
+			m:    &Marshaler{Sort: "-Col4, -Col3"},
+			v:    rows,
+			want: []string{"a", "c", "b"},
+		},
+		{
+			name: "field path with nil message first",
+			m:    &Marshaler{Sort: "Outer.Col1"},
+			v:    rows,
+			want: []string{"a", "c", "b"},
+		},
+		{
+			name: "header name of nested field",
+			m:    &Marshaler{Sort: "Col1"},
+			v:    rows,
+			want: []string{"a", "c", "b"},
+		},
+		{
+			name: "unknown field",
+			m:    &Marshaler{Sort: "Unknown,-Col3"},
+			v:    rows,
+			want: []string{"c", "b", "a"},
+		},
+		{
+			name: "timestamps",
+			m:    &Marshaler{Sort: "-Created"},
+			v:    events,
+			want: []string{"later", "earlier", "unset"},
+		},
+		{
+			name: "decimals",
+			m:    &Marshaler{Sort: "Amount"},
+			v:    items,
+			want: []string{"b", "c", "a"},
+		},
+		{
+			name: "money",
+			m:    &Marshaler{Sort: "Price"},
+			v:    items,
+			want: []string{"c", "b", "a"},
+		},
+		{
+			name: "unsupported message",
+			m:    &Marshaler{Sort: "Day,Name"},
+			v:    items,
+			want: []string{"a", "b", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.m.NoHeader = true
+			g, err := tt.m.Marshal(tt.v)
+			if err != nil {
+				t.Errorf("CSVMarshaler.Marshal() error = %v", err)
+				return
+			}
+			got := []string{}
+			for _, row := range strings.Split(strings.TrimSuffix(string(g), "\n"), "\n") {
+				got = append(got, strings.Split(row, ";")[0])
+			}
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshaler_MarshalSortParam(t *testing.T) {
+	api := &apipb.Api{Mixins: []*apipb.Mixin{{Name: "a"}, {Name: "c"}, {Name: "b"}}}
+	m := &Marshaler{
+		Tables:    map[string]string{"google.protobuf.Api": "mixins"},
+		Sort:      "name",
+		SortParam: "csv.sort",
+	}
+	h := gateway(t, m, api)
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name: "default",
+			want: "Name;Root\na;\nb;\nc;\n",
+		},
+		{
+			name:  "query parameter",
+			query: "?csv.sort=-name",
+			want:  "Name;Root\nc;\nb;\na;\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/test"+tt.query, nil)
+			req.Header.Set("Accept", "text/csv")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			got := w.Body.String()
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("CSVMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
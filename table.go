@@ -0,0 +1,191 @@
+package csv
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// Align specifies the alignment of values within a fixed-width column.
+type Align int
+
+const (
+	// AlignLeft pads values on the right.
+	AlignLeft Align = iota
+	// AlignRight pads values on the left.
+	AlignRight
+	// AlignCenter pads values on both sides.
+	AlignCenter
+)
+
+// Border specifies the border drawn around a table.
+type Border int
+
+const (
+	// BorderNone draws no border, columns are delimited by a single space.
+	BorderNone Border = iota
+	// BorderASCII draws the border using '+', '-' and '|'.
+	BorderASCII
+	// BorderUnicode draws the border using unicode box drawing characters.
+	BorderUnicode
+)
+
+// borderChars holds the characters used to draw a border:
+// horizontal, vertical and the corners / crossings from top left to bottom right.
+type borderChars struct {
+	h, v                               string
+	tl, tm, tr, ml, mm, mr, bl, bm, br string
+}
+
+var borders = map[Border]borderChars{
+	BorderASCII:   {"-", "|", "+", "+", "+", "+", "+", "+", "+", "+", "+"},
+	BorderUnicode: {"─", "│", "┌", "┬", "┐", "├", "┼", "┤", "└", "┴", "┘"},
+}
+
+// TableMarshaler renders responses as column aligned fixed-width text.
+//
+// Flattening of the response is the same as for Marshaler, FieldDelim
+// is not used. Blocks of multiple top-level slices are delimited by an
+// empty line.
+type TableMarshaler struct {
+	Marshaler
+
+	// Widths declares fixed widths per column (by header name, translated if Catalog is set).
+	// Longer values are truncated.
+	// Columns without declared width are as wide as their widest value.
+	Widths map[string]int
+	// Aligns declares the alignment per column (by header name, translated if Catalog is set).
+	// Defaults to AlignLeft.
+	Aligns map[string]Align
+	// Border specifies the border drawn around the table.
+	Border Border
+}
+
+// Marshal renders the structure in i as fixed-width text table.
+//
+// See Marshaler.Marshal for the flattening rules.
+func (m *TableMarshaler) Marshal(i interface{}) ([]byte, error) {
+	return m.MarshalContext(boundContext(i), i)
+}
+
+// MarshalContext renders the structure in i as fixed-width text table
+// applying request specific options, see Marshaler.MarshalContext.
+func (m *TableMarshaler) MarshalContext(ctx context.Context, i interface{}) ([]byte, error) {
+	m.initDefaults()
+	c := *m
+	c.Marshaler = *m.forRequest(ctx)
+	m = &c
+
+	blocks, err := m.blocks(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		tables = append(tables, m.renderTable(b))
+	}
+	return m.compress(m.encode(strings.Join(tables, m.RowDelim)))
+}
+
+func (m *TableMarshaler) renderTable(b block) string {
+	if b.header == nil {
+		// header exceeded MaxBytes
+		return m.title(b) + m.TruncateMarker + m.RowDelim
+	}
+	widths := make([]int, len(b.header))
+	for j, h := range b.header {
+		if w, ok := m.Widths[h]; ok {
+			widths[j] = w
+			continue
+		}
+		if !m.NoHeader {
+			widths[j] = utf8.RuneCountInString(h)
+		}
+		for _, row := range b.rows {
+			if j < len(row) && utf8.RuneCountInString(row[j]) > widths[j] {
+				widths[j] = utf8.RuneCountInString(row[j])
+			}
+		}
+	}
+
+	bc, border := borders[m.Border]
+	line := func(l, mid, r string) string {
+		s := make([]string, len(widths))
+		for j, w := range widths {
+			s[j] = strings.Repeat(bc.h, w+2)
+		}
+		return l + strings.Join(s, mid) + r + m.RowDelim
+	}
+	row := func(cells []string) string {
+		s := make([]string, len(widths))
+		for j, w := range widths {
+			c := ""
+			if j < len(cells) {
+				c = cells[j]
+			}
+			s[j] = pad(c, w, m.Aligns[b.header[j]])
+		}
+		if !border {
+			return strings.Join(s, " ") + m.RowDelim
+		}
+		return bc.v + " " + strings.Join(s, " "+bc.v+" ") + " " + bc.v + m.RowDelim
+	}
+
+	res := m.title(b)
+	if border {
+		res += line(bc.tl, bc.tm, bc.tr)
+	}
+	if !m.NoHeader {
+		res += row(b.header)
+		if border {
+			res += line(bc.ml, bc.mm, bc.mr)
+		}
+	}
+	for _, r := range b.rows {
+		res += row(r)
+	}
+	if border {
+		res += line(bc.bl, bc.bm, bc.br)
+	}
+	if b.truncated {
+		res += m.TruncateMarker + m.RowDelim
+	}
+	return res
+}
+
+// title returns the title line of the block b if BlockTitle is set.
+func (m *TableMarshaler) title(b block) string {
+	if m.BlockTitle {
+		return "# " + b.title() + m.RowDelim
+	}
+	return ""
+}
+
+// pad pads or truncates s to exactly w runes.
+func pad(s string, w int, a Align) string {
+	n := utf8.RuneCountInString(s)
+	if n > w {
+		return string([]rune(s)[:w])
+	}
+	switch a {
+	case AlignRight:
+		return strings.Repeat(" ", w-n) + s
+	case AlignCenter:
+		l := (w - n) / 2
+		return strings.Repeat(" ", l) + s + strings.Repeat(" ", w-n-l)
+	default:
+		return s + strings.Repeat(" ", w-n)
+	}
+}
+
+// ContentType returns 'text/plain' with the charset parameter set according
+// to the Encoding option or 'application/gzip' with the Gzip option.
+func (m *TableMarshaler) ContentType(v interface{}) string {
+	if m.Gzip {
+		return "application/gzip"
+	}
+	return m.withCharset("text/plain")
+}
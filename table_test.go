@@ -0,0 +1,113 @@
+package csv
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestTableMarshaler_Marshal(t *testing.T) {
+
+	v := []inner{
+		{
+			Col3: "a",
+			Col4: 1,
+			Col5: 1.5,
+		},
+		{
+			Col3: "bcdef",
+			Col4: 200,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		m       *TableMarshaler
+		v       interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "computed widths",
+			m:    &TableMarshaler{},
+			v:    v,
+			want: "Col3  Col4 Col5\n" +
+				"a     1    1.5 \n" +
+				"bcdef 200  0   \n",
+		},
+		{
+			name: "declared widths and alignment",
+			m: &TableMarshaler{
+				Widths: map[string]int{"Col3": 3, "Col5": 6},
+				Aligns: map[string]Align{"Col4": AlignRight, "Col5": AlignCenter},
+			},
+			v: v,
+			want: "Col Col4  Col5 \n" +
+				"a      1  1.5  \n" +
+				"bcd  200   0   \n",
+		},
+		{
+			name: "header exceeds max bytes",
+			m:    &TableMarshaler{Border: BorderASCII, Marshaler: Marshaler{MaxBytes: 10, Truncate: true}},
+			v:    v,
+			want: "# truncated\n",
+		},
+		{
+			name: "ascii border",
+			m:    &TableMarshaler{Border: BorderASCII},
+			v:    v[:1],
+			want: "+------+------+------+\n" +
+				"| Col3 | Col4 | Col5 |\n" +
+				"+------+------+------+\n" +
+				"| a    | 1    | 1.5  |\n" +
+				"+------+------+------+\n",
+		},
+		{
+			name: "unicode border w/o header",
+			m:    &TableMarshaler{Border: BorderUnicode, Marshaler: Marshaler{NoHeader: true}},
+			v:    v[:1],
+			want: "┌───┬───┬─────┐\n" +
+				"│ a │ 1 │ 1.5 │\n" +
+				"└───┴───┴─────┘\n",
+		},
+		{
+			name: "multiple slices",
+			m:    &TableMarshaler{},
+			v: struct {
+				a []inner
+				b []inner
+			}{
+				a: v[:1],
+				b: v[1:],
+			},
+			want: "Col3 Col4 Col5\na    1    1.5 \n\nCol3  Col4 Col5\nbcdef 200  0   \n",
+		},
+		{
+			name:    "slice w/o struct",
+			m:       &TableMarshaler{},
+			v:       []string{"a"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.m.Marshal(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TableMarshaler.Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			got := string(g)
+			if diff := pretty.Compare(got, tt.want); diff != "" {
+				t.Errorf("TableMarshaler.Marshal() generate unexpected results:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTableMarshaler_ContentType(t *testing.T) {
+	want := "text/plain"
+	m := &TableMarshaler{}
+	if got := m.ContentType(nil); got != want {
+		t.Errorf("TableMarshaler.ContentType() = %v, want %v", got, want)
+	}
+}